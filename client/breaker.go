@@ -0,0 +1,328 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-http/server"
+	"github.com/altstory/go-log"
+)
+
+const (
+	// DefaultBreakerOpenTimeout 是熔断之后进入半开状态前的默认等待时间。
+	DefaultBreakerOpenTimeout = 5 * time.Second
+
+	// DefaultBreakerHalfOpenRequests 是半开状态下默认允许的探测请求数。
+	DefaultBreakerHalfOpenRequests = 1
+)
+
+var (
+	// ErrCircuitOpen 代表熔断器处于打开状态，请求没有发出。
+	ErrCircuitOpen = errors.New("go-http: circuit breaker is open")
+
+	// ErrBulkheadFull 代表并发请求数已经达到上限，请求没有发出。
+	ErrBulkheadFull = errors.New("go-http: too many concurrent requests")
+)
+
+// BreakerState 是熔断器的状态。
+type BreakerState int
+
+// 熔断器的所有状态。
+const (
+	BreakerClosed   BreakerState = iota // BreakerClosed 代表熔断器关闭，请求正常发出。
+	BreakerOpen                         // BreakerOpen 代表熔断器打开，所有请求直接失败。
+	BreakerHalfOpen                     // BreakerHalfOpen 代表熔断器半开，只允许少量请求探测服务是否恢复。
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// breakerConfig 是熔断器和并发限制的配置，同一个 Client 的所有 endpoint 共享这个配置。
+type breakerConfig struct {
+	failures         int
+	openTimeout      time.Duration
+	halfOpenRequests int
+	errCodes         map[int]bool
+	maxConcurrency   int
+}
+
+// breaker 是一个 host + endpoint 的熔断器，同时也负责限制并发请求数。
+type breaker struct {
+	key    string
+	config *breakerConfig
+
+	mu               sync.Mutex
+	state            BreakerState
+	failures         int
+	openedAt         time.Time
+	halfOpenInflight int
+
+	// generation 在每次状态变化时加一，用来识别在之前的状态下发出的请求。
+	generation uint64
+
+	// sem 用来实现 bulkhead，为 nil 表示不限制并发。
+	sem chan struct{}
+}
+
+// breakers 管理一个 Client 的所有熔断器。
+type breakers struct {
+	config *breakerConfig
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func newBreakers(config *Config) *breakers {
+	bc := &breakerConfig{
+		failures:         config.BreakerFailures,
+		openTimeout:      config.BreakerOpenTimeout,
+		halfOpenRequests: config.BreakerHalfOpenRequests,
+		errCodes:         make(map[int]bool, len(config.BreakerErrCodes)),
+		maxConcurrency:   config.MaxConcurrency,
+	}
+
+	if bc.openTimeout <= 0 {
+		bc.openTimeout = DefaultBreakerOpenTimeout
+	}
+
+	if bc.halfOpenRequests <= 0 {
+		bc.halfOpenRequests = DefaultBreakerHalfOpenRequests
+	}
+
+	for _, code := range config.BreakerErrCodes {
+		bc.errCodes[code] = true
+	}
+
+	return &breakers{
+		config:   bc,
+		breakers: map[string]*breaker{},
+	}
+}
+
+// get 返回 key 对应的熔断器，如果熔断和并发限制都没有启用则返回 nil。
+func (bs *breakers) get(key string) *breaker {
+	if bs.config.failures <= 0 && bs.config.maxConcurrency <= 0 {
+		return nil
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if b, ok := bs.breakers[key]; ok {
+		return b
+	}
+
+	b := &breaker{
+		key:    key,
+		config: bs.config,
+	}
+
+	if bs.config.maxConcurrency > 0 {
+		b.sem = make(chan struct{}, bs.config.maxConcurrency)
+	}
+
+	bs.breakers[key] = b
+	return b
+}
+
+// currentState 返回熔断器当前的状态，打开状态超时之后会被视为半开状态。
+func (b *breaker) currentState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.config.openTimeout {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// acquire 判断请求是否可以发出，如果可以发出，调用者必须在请求结束后用返回的 generation 调用 release。
+func (b *breaker) acquire(ctx context.Context) (generation uint64, err error) {
+	generation, err = b.allow(ctx)
+
+	if err != nil {
+		clientMetrics.BreakerReject.AddForTag(b.key, 1)
+		return
+	}
+
+	if b.sem == nil {
+		return
+	}
+
+	select {
+	case b.sem <- struct{}{}:
+		clientMetrics.Concurrency.AddForTag(b.key, int64(len(b.sem)))
+		return
+	default:
+	}
+
+	// 没有拿到并发名额，需要归还半开状态下的探测名额。
+	b.mu.Lock()
+
+	if b.generation == generation && b.state == BreakerHalfOpen && b.halfOpenInflight > 0 {
+		b.halfOpenInflight--
+	}
+
+	b.mu.Unlock()
+
+	clientMetrics.BulkheadReject.AddForTag(b.key, 1)
+	log.Warnf(ctx, "key=%v||max_concurrency=%v||go-http: bulkhead is full", b.key, b.config.maxConcurrency)
+	return 0, ErrBulkheadFull
+}
+
+func (b *breaker) allow(ctx context.Context) (uint64, error) {
+	if b.config.failures <= 0 {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.openTimeout {
+			return 0, ErrCircuitOpen
+		}
+
+		b.setState(ctx, BreakerHalfOpen)
+		fallthrough
+
+	case BreakerHalfOpen:
+		if b.halfOpenInflight >= b.config.halfOpenRequests {
+			return 0, ErrCircuitOpen
+		}
+
+		b.halfOpenInflight++
+	}
+
+	return b.generation, nil
+}
+
+// release 归还并发名额，并根据请求结果更新熔断器状态。
+//
+// 如果熔断器在请求发出之后已经改变了状态，这个请求的结果不再能反映当前状态下服务的情况，
+// 例如熔断之前发出的请求在半开状态下才成功返回，这种结果会被忽略。
+func (b *breaker) release(ctx context.Context, generation uint64, outcome breakerOutcome) {
+	if b.sem != nil {
+		<-b.sem
+	}
+
+	if b.config.failures <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case BreakerClosed:
+		switch outcome {
+		case outcomeNeutral:
+			return
+		case outcomeSuccess:
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+
+		if b.failures >= b.config.failures {
+			b.open(ctx)
+		}
+
+	case BreakerHalfOpen:
+		if b.halfOpenInflight > 0 {
+			b.halfOpenInflight--
+		}
+
+		switch outcome {
+		case outcomeNeutral:
+			// 探测请求被调用者取消了，没有测试到服务的情况，只归还探测名额。
+			return
+		case outcomeFailure:
+			b.open(ctx)
+			return
+		}
+
+		b.failures = 0
+		b.setState(ctx, BreakerClosed)
+	}
+}
+
+// breakerOutcome 是一次请求结果对熔断器的影响。
+type breakerOutcome int
+
+const (
+	outcomeSuccess breakerOutcome = iota // outcomeSuccess 表示请求成功。
+	outcomeFailure                       // outcomeFailure 表示请求失败，计入失败次数。
+	outcomeNeutral                       // outcomeNeutral 表示请求被调用者取消，不影响熔断器状态。
+)
+
+// outcome 判断一次请求结果对熔断器的影响。
+//
+// 以下情况视为失败：
+//     - 网络错误或者超时；
+//     - 服务返回了 5xx 状态码；
+//     - 服务返回了 Config.BreakerErrCodes 中的业务错误码。
+//
+// 调用者主动取消的请求没有测试到服务的情况，既不算成功也不算失败。
+func (b *breaker) outcome(ctx context.Context, result *attemptResult) breakerOutcome {
+	if result.err == nil {
+		return outcomeSuccess
+	}
+
+	if ctx.Err() == context.Canceled {
+		return outcomeNeutral
+	}
+
+	if result.business {
+		if b.config.errCodes[server.ErrorCode(result.err)] {
+			return outcomeFailure
+		}
+
+		return outcomeSuccess
+	}
+
+	if result.status == 0 || result.status >= 500 {
+		return outcomeFailure
+	}
+
+	return outcomeSuccess
+}
+
+func (b *breaker) open(ctx context.Context) {
+	b.openedAt = time.Now()
+	b.halfOpenInflight = 0
+	b.setState(ctx, BreakerOpen)
+	clientMetrics.BreakerOpen.AddForTag(b.key, 1)
+}
+
+// setState 修改熔断器状态，调用者需要持有 b.mu。
+func (b *breaker) setState(ctx context.Context, state BreakerState) {
+	if b.state == state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+	b.generation++
+	clientMetrics.BreakerTransition.AddForTag(b.key+":"+state.String(), 1)
+	log.Warnf(ctx, "key=%v||from=%v||to=%v||failures=%v||go-http: circuit breaker state changes", b.key, from, state, b.failures)
+}
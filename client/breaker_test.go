@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/altstory/go-http/server"
+	"github.com/huandu/go-assert"
+)
+
+func TestClientBreaker(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	var healthy, count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(`{"err":0,"msg":"","data":{"foo":"ok"}}`))
+	}))
+	defer ts.Close()
+
+	c := New(&Config{
+		BaseURL:            ts.URL,
+		BreakerFailures:    3,
+		BreakerOpenTimeout: 50 * time.Millisecond,
+	})
+
+	// 连续失败 3 次后熔断，之后的请求不会发出。
+	for i := 0; i < 3; i++ {
+		a.Assert(c.Call(ctx, server.GET, "/breaker", nil, nil) != nil)
+	}
+
+	a.Equal(c.BreakerState("/breaker"), BreakerOpen)
+	a.Equal(c.Call(ctx, server.GET, "/breaker", nil, nil), ErrCircuitOpen)
+	a.Equal(atomic.LoadInt32(&count), int32(3))
+
+	// 熔断器按照 uri 区分，其他接口不受影响。
+	a.Equal(c.BreakerState("/other"), BreakerClosed)
+
+	// 半开状态下探测失败会再次熔断。
+	time.Sleep(60 * time.Millisecond)
+	a.Equal(c.BreakerState("/breaker"), BreakerHalfOpen)
+	a.Assert(c.Call(ctx, server.GET, "/breaker", nil, nil) != ErrCircuitOpen)
+	a.Equal(c.BreakerState("/breaker"), BreakerOpen)
+	a.Equal(atomic.LoadInt32(&count), int32(4))
+
+	// 服务恢复后，半开状态下探测成功会关闭熔断器。
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	a.NilError(c.Call(ctx, server.GET, "/breaker", nil, nil))
+	a.Equal(c.BreakerState("/breaker"), BreakerClosed)
+}
+
+func TestClientBreakerErrCodes(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	var code int32 = 100
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"err":%v,"msg":"failed"}`, atomic.LoadInt32(&code))
+	}))
+	defer ts.Close()
+
+	c := New(&Config{
+		BaseURL:         ts.URL,
+		BreakerFailures: 2,
+		BreakerErrCodes: []int{101},
+	})
+
+	// 不在 BreakerErrCodes 里的业务错误不算失败。
+	for i := 0; i < 3; i++ {
+		a.Equal(server.ErrorCode(c.Call(ctx, server.GET, "/code", nil, nil)), 100)
+	}
+
+	a.Equal(c.BreakerState("/code"), BreakerClosed)
+
+	atomic.StoreInt32(&code, 101)
+
+	for i := 0; i < 2; i++ {
+		a.Equal(server.ErrorCode(c.Call(ctx, server.GET, "/code", nil, nil)), 101)
+	}
+
+	a.Equal(c.BreakerState("/code"), BreakerOpen)
+}
+
+func TestClientBulkhead(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+		w.Write([]byte(`{"err":0,"msg":""}`))
+	}))
+	defer ts.Close()
+
+	c := New(&Config{
+		BaseURL:        ts.URL,
+		MaxConcurrency: 1,
+		MaxRetries:     2,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Call(ctx, server.GET, "/slow", nil, nil)
+	}()
+	<-entered
+
+	// 并发名额用完之后请求直接失败，也不会重试。
+	a.Equal(c.Call(ctx, server.GET, "/slow", nil, nil), ErrBulkheadFull)
+
+	close(unblock)
+	a.NilError(<-done)
+
+	// 名额归还后可以继续请求。
+	go func() {
+		<-entered
+	}()
+	a.NilError(c.Call(ctx, server.GET, "/slow", nil, nil))
+}
+
+func TestBreakerStaleRelease(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	bs := newBreakers(&Config{
+		BreakerFailures:    1,
+		BreakerOpenTimeout: 50 * time.Millisecond,
+	})
+	b := bs.get("/stale")
+
+	// 熔断之前发出的请求。
+	stale, err := b.acquire(ctx)
+	a.NilError(err)
+
+	gen, err := b.acquire(ctx)
+	a.NilError(err)
+	b.release(ctx, gen, outcomeFailure)
+	a.Equal(b.currentState(), BreakerOpen)
+
+	// 进入半开状态之后，熔断之前发出的请求成功返回，不能因此关闭熔断器，也不能占用探测名额。
+	time.Sleep(60 * time.Millisecond)
+	probe, err := b.acquire(ctx)
+	a.NilError(err)
+	b.release(ctx, stale, outcomeSuccess)
+	a.Equal(b.currentState(), BreakerHalfOpen)
+	a.Equal(b.halfOpenInflight, 1)
+
+	b.release(ctx, probe, outcomeSuccess)
+	a.Equal(b.currentState(), BreakerClosed)
+}
+
+func TestBreakerCanceledProbe(t *testing.T) {
+	a := assert.New(t)
+	bs := newBreakers(&Config{
+		BreakerFailures:    2,
+		BreakerOpenTimeout: 50 * time.Millisecond,
+	})
+	b := bs.get("/canceled")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := &attemptResult{err: context.Canceled}
+	a.Equal(b.outcome(ctx, canceled), outcomeNeutral)
+
+	// 关闭状态下取消的请求不会重置连续失败次数。
+	gen, err := b.acquire(context.Background())
+	a.NilError(err)
+	b.release(ctx, gen, outcomeFailure)
+	gen, err = b.acquire(context.Background())
+	a.NilError(err)
+	b.release(ctx, gen, outcomeNeutral)
+	a.Equal(b.failures, 1)
+	gen, err = b.acquire(context.Background())
+	a.NilError(err)
+	b.release(ctx, gen, outcomeFailure)
+	a.Equal(b.currentState(), BreakerOpen)
+
+	// 半开状态下取消的探测请求只归还探测名额，熔断器保持半开。
+	time.Sleep(60 * time.Millisecond)
+	probe, err := b.acquire(context.Background())
+	a.NilError(err)
+	a.Equal(b.halfOpenInflight, 1)
+	b.release(ctx, probe, b.outcome(ctx, canceled))
+	a.Equal(b.currentState(), BreakerHalfOpen)
+	a.Equal(b.halfOpenInflight, 0)
+
+	probe, err = b.acquire(context.Background())
+	a.NilError(err)
+	b.release(context.Background(), probe, outcomeSuccess)
+	a.Equal(b.currentState(), BreakerClosed)
+}
@@ -0,0 +1,424 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/altstory/go-http/server"
+	"github.com/altstory/go-log"
+)
+
+// Client 代表一个 HTTP client，用来调用通过 go-http/server 实现的服务。
+type Client struct {
+	name    string
+	baseURL string
+	host    string
+	client  *http.Client
+	timeout time.Duration
+
+	maxResponseBytes int64
+
+	maxRetries      int
+	retryBackoff    time.Duration
+	retryMaxBackoff time.Duration
+	retryErrCodes   map[int]bool
+
+	breakers *breakers
+}
+
+// envelope 是 go-http/server 业务应答的外层结构。
+type envelope struct {
+	Err  *int            `json:"err"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// New 创建一个新的 Client。
+func New(config *Config) *Client {
+	timeout := config.Timeout
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	maxResponseBytes := config.MaxResponseBytes
+
+	if maxResponseBytes == 0 {
+		maxResponseBytes = DefaultMaxResponseBytes
+	}
+
+	retryBackoff := config.RetryBackoff
+
+	if retryBackoff <= 0 {
+		retryBackoff = DefaultRetryBackoff
+	}
+
+	retryMaxBackoff := config.RetryMaxBackoff
+
+	if retryMaxBackoff <= 0 {
+		retryMaxBackoff = DefaultRetryMaxBackoff
+	}
+
+	if retryMaxBackoff < retryBackoff {
+		retryMaxBackoff = retryBackoff
+	}
+
+	maxRetries := config.MaxRetries
+
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	retryErrCodes := make(map[int]bool, len(config.RetryErrCodes))
+
+	for _, code := range config.RetryErrCodes {
+		retryErrCodes[code] = true
+	}
+
+	var host string
+
+	if u, err := url.Parse(config.BaseURL); err == nil {
+		host = u.Host
+	}
+
+	transport := config.Transport
+
+	if transport == nil {
+		transport = newTransport(config, timeout)
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(config.BaseURL, "/"),
+		host:    host,
+		client: &http.Client{
+			Transport: transport,
+		},
+		timeout: timeout,
+
+		maxResponseBytes: maxResponseBytes,
+
+		maxRetries:      maxRetries,
+		retryBackoff:    retryBackoff,
+		retryMaxBackoff: retryMaxBackoff,
+		retryErrCodes:   retryErrCodes,
+
+		breakers: newBreakers(config),
+	}
+}
+
+func newTransport(config *Config, timeout time.Duration) *http.Transport {
+	dialTimeout := config.DialTimeout
+
+	if dialTimeout <= 0 {
+		dialTimeout = timeout
+	}
+
+	maxIdleConnsPerHost := config.MaxIdleConnsPerHost
+
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+
+	idleConnTimeout := config.IdleConnTimeout
+
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = DefaultIdleConnTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// Name 返回 Client 的名字，即配置文件中 `[http.client.<name>]` 的 name。
+// 直接通过 New 创建的 Client 名字为空。
+func (c *Client) Name() string {
+	return c.name
+}
+
+// BreakerState 返回 uri 对应的熔断器状态，如果没有启用熔断则总是返回 BreakerClosed。
+func (c *Client) BreakerState(uri string) BreakerState {
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri
+	}
+
+	b := c.breakers.get(c.host + uri)
+
+	if b == nil {
+		return BreakerClosed
+	}
+
+	return b.currentState()
+}
+
+// Call 调用 uri 对应的业务接口，将 req 编码成请求参数，并将应答中的 data 解析到 res 里。
+//
+// req 的编码方式与 go-http/server 解析业务请求的方式一致：
+//     - GET 请求的所有字段都放在 query 里，参数名来自 `form` tag，没有 tag 则使用字段名；
+//     - 其他请求中，设置了 `form` tag 的字段放在 query 里，整个 req 通过 JSON 编码放在 body 里。
+//
+// 如果 ctx 是 go-http/server 传给业务处理函数的 ctx，请求会带上 server.HeaderTraceID，
+// 这样上下游的日志可以通过同一个 traceid 串起来。
+//
+// 如果服务返回了业务错误，返回的 error 可以通过 server.ErrorCode 得到错误码；
+// 如果发生了网络错误或者服务没有返回标准格式的应答，错误码是 server.ErrCodeTransportError。
+func (c *Client) Call(ctx context.Context, method server.Method, uri string, req, res interface{}, opts ...CallOption) error {
+	co := &callOptions{
+		timeout:    c.timeout,
+		maxRetries: c.maxRetries,
+	}
+
+	for _, opt := range opts {
+		opt(co)
+	}
+
+	if method == server.ANY {
+		method = server.POST
+	}
+
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri
+	}
+
+	start := time.Now()
+	u, body, err := c.encodeRequest(method, uri, req)
+
+	if err != nil {
+		return err
+	}
+
+	var result *attemptResult
+	retries := 0
+
+	for {
+		result = c.attempt(ctx, co, method, uri, u, body, res)
+
+		if retries >= co.maxRetries || !c.shouldRetry(method, result) {
+			break
+		}
+
+		timer := time.NewTimer(c.backoff(retries))
+		canceled := false
+
+		select {
+		case <-ctx.Done():
+			canceled = true
+		case <-timer.C:
+		}
+
+		timer.Stop()
+
+		if canceled {
+			break
+		}
+
+		retries++
+		clientMetrics.Retry.AddForTag(c.metricsTag(uri), 1)
+	}
+
+	c.report(ctx, method, uri, start, retries, result)
+	return result.err
+}
+
+// attempt 在熔断器和并发限制的保护下发送一次请求。
+// 熔断器和并发限制按照 host + uri 区分，uri 不包含 query。
+func (c *Client) attempt(ctx context.Context, co *callOptions, method server.Method, uri, u string, body []byte, res interface{}) (result *attemptResult) {
+	b := c.breakers.get(c.host + uri)
+
+	if b == nil {
+		return c.do(ctx, co, method, u, body, res)
+	}
+
+	generation, err := b.acquire(ctx)
+
+	if err != nil {
+		return &attemptResult{
+			err:      err,
+			rejected: true,
+		}
+	}
+
+	defer func() {
+		b.release(ctx, generation, b.outcome(ctx, result))
+	}()
+
+	return c.do(ctx, co, method, u, body, res)
+}
+
+// do 发送一次请求，每次请求都会单独计算超时时间。
+func (c *Client) do(ctx context.Context, co *callOptions, method server.Method, u string, body []byte, res interface{}) *attemptResult {
+	ctx, cancel := context.WithTimeout(ctx, co.timeout)
+	defer cancel()
+
+	var reader io.Reader
+
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	r, err := http.NewRequest(method.String(), u, reader)
+
+	if err != nil {
+		return &attemptResult{err: err}
+	}
+
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	for k, v := range co.header {
+		r.Header[k] = v
+	}
+
+	if traceid := server.TraceID(ctx); traceid != "" {
+		r.Header.Set(server.HeaderTraceID, traceid)
+	}
+
+	resp, err := c.client.Do(r.WithContext(ctx))
+
+	if err != nil {
+		return &attemptResult{err: server.TransportError(err)}
+	}
+
+	defer resp.Body.Close()
+	business, err := decodeResponse(resp, res, c.maxResponseBytes)
+	return &attemptResult{
+		status:   resp.StatusCode,
+		business: business,
+		err:      err,
+	}
+}
+
+// report 在调用结束后记录日志和监控。
+func (c *Client) report(ctx context.Context, method server.Method, uri string, start time.Time, retries int, result *attemptResult) {
+	proctime := time.Now().Sub(start)
+	proctimeMS := int64(proctime / time.Millisecond)
+	tag := c.metricsTag(uri)
+
+	clientMetrics.QPS.AddForTag(tag, 1)
+	clientMetrics.Count.AddForTag(tag, 1)
+	clientMetrics.ProcTime.AddForTag(tag, proctimeMS)
+	clientMetrics.MaxProcTime.AddForTag(tag, proctimeMS)
+
+	code := server.ErrCodeOK
+
+	if result.err != nil {
+		clientMetrics.Failure.AddForTag(tag, 1)
+		code = server.ErrorCode(result.err)
+	}
+
+	ctx = log.WithTag(ctx, "http.client")
+	log.Tracef(ctx, "client=%v||url=%v||method=%v||status=%v||code=%v||retries=%v||proctime=%.6f||err=%v||go-http: client request ends",
+		c.name, uri, method, result.status, code, retries, proctime.Seconds(), result.err)
+}
+
+func (c *Client) metricsTag(uri string) string {
+	if c.name == "" {
+		return uri
+	}
+
+	return c.name + ":" + uri
+}
+
+// encodeRequest 将 req 编码成请求的 URL 和 body，body 会在重试时复用。
+func (c *Client) encodeRequest(method server.Method, uri string, req interface{}) (u string, body []byte, err error) {
+	query, err := encodeQuery(req, method != server.GET)
+
+	if err != nil {
+		return
+	}
+
+	if method != server.GET && req != nil {
+		body, err = json.Marshal(req)
+
+		if err != nil {
+			err = fmt.Errorf("go-http: fail to encode request in JSON [err:%v]", err)
+			return
+		}
+	}
+
+	u = c.baseURL + uri
+
+	if q := query.Encode(); q != "" {
+		u += "?" + q
+	}
+
+	return
+}
+
+// decodeResponse 解析 `{"err":0,"msg":"","data":{}}` 格式的应答，将 data 解析到 res 里。
+// 如果服务返回了业务错误，business 为 true。应答 body 超过 limit 时返回错误，limit 小于 0 表示不限制。
+func decodeResponse(resp *http.Response, res interface{}, limit int64) (business bool, err error) {
+	body := io.Reader(resp.Body)
+
+	if limit >= 0 {
+		// 多读一个字节，用来判断应答是否超过了大小限制。
+		body = io.LimitReader(body, limit+1)
+	}
+
+	data, err := ioutil.ReadAll(body)
+
+	if err != nil {
+		err = server.TransportError(err)
+		return
+	}
+
+	if limit >= 0 && int64(len(data)) > limit {
+		err = server.TransportError(fmt.Errorf("go-http: response is too large [status:%v] [limit:%v]", resp.StatusCode, limit))
+		return
+	}
+
+	var env envelope
+
+	if err = json.Unmarshal(data, &env); err != nil || env.Err == nil {
+		err = server.TransportError(fmt.Errorf("go-http: unexpected response [status:%v] [body:%s]", resp.StatusCode, truncate(data, 256)))
+		return
+	}
+
+	// 应答中的 msg 已经是包装过的错误信息，原样保留，避免每经过一个服务就嵌套一层。
+	if *env.Err != server.ErrCodeOK {
+		business = true
+		err = server.RemoteError(*env.Err, env.Msg)
+		return
+	}
+
+	if res == nil || len(env.Data) == 0 || string(env.Data) == "null" {
+		return
+	}
+
+	if err = json.Unmarshal(env.Data, res); err != nil {
+		err = server.TransportError(fmt.Errorf("go-http: fail to decode response data [err:%v]", err))
+		return
+	}
+
+	return
+}
+
+func truncate(data []byte, n int) []byte {
+	if len(data) <= n {
+		return data
+	}
+
+	return data[:n]
+}
@@ -0,0 +1,281 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altstory/go-http/server"
+	"github.com/huandu/go-assert"
+)
+
+const (
+	testUsername = "huandu"
+	testPassword = "a-passport"
+	testUID      = 906
+	testToken    = "a-token"
+)
+
+type testLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"passport"`
+}
+
+type testValidateRequest struct {
+	UID   int64  `form:"uid" json:"-"`
+	Token string `json:"token"`
+}
+
+type testSearchRequest struct {
+	Keyword string    `form:"keyword"`
+	Tags    []string  `form:"tag"`
+	Since   time.Time `form:"since"`
+	Limit   int
+}
+
+type testResponse struct {
+	Foo string `json:"foo"`
+	Bar int    `json:"bar"`
+}
+
+func testLogin(ctx context.Context, req *testLoginRequest) (*testResponse, error) {
+	if req.Username != testUsername || req.Password != testPassword {
+		return nil, server.Error(100, "invalid password")
+	}
+
+	return &testResponse{Foo: "login", Bar: 1}, nil
+}
+
+func testValidate(ctx context.Context, req *testValidateRequest) (*testResponse, error) {
+	if req.UID != testUID || req.Token != testToken {
+		return nil, server.Error(101, "invalid token")
+	}
+
+	return &testResponse{Foo: "validate", Bar: 2}, nil
+}
+
+func testSearch(ctx context.Context, req *testSearchRequest) (*testResponse, error) {
+	return &testResponse{Foo: req.Keyword + req.Since.UTC().Format(time.RFC3339), Bar: len(req.Tags)*100 + req.Limit}, nil
+}
+
+func newTestServer() *httptest.Server {
+	s := server.New(&server.Config{})
+	s.AddRoutes(server.RouteMap{
+		"/passport": server.RouteList{
+			server.R("login", server.POST, testLogin),
+			server.R("validate", server.ANY, testValidate),
+			server.R("search", server.GET, testSearch),
+		},
+	})
+	return httptest.NewServer(s.Handler())
+}
+
+func TestClientCall(t *testing.T) {
+	a := assert.New(t)
+	ts := newTestServer()
+	defer ts.Close()
+
+	c := New(&Config{
+		BaseURL: ts.URL + "/",
+	})
+	ctx := context.Background()
+
+	var res testResponse
+	a.NilError(c.Call(ctx, server.POST, "/passport/login", &testLoginRequest{
+		Username: testUsername,
+		Password: testPassword,
+	}, &res))
+	a.Equal(res, testResponse{Foo: "login", Bar: 1})
+
+	res = testResponse{}
+	a.NilError(c.Call(ctx, server.ANY, "passport/validate", &testValidateRequest{
+		UID:   testUID,
+		Token: testToken,
+	}, &res))
+	a.Equal(res, testResponse{Foo: "validate", Bar: 2})
+
+	res = testResponse{}
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a.NilError(c.Call(ctx, server.GET, "/passport/search", &testSearchRequest{
+		Keyword: "k",
+		Tags:    []string{"a", "b", ""},
+		Since:   since,
+		Limit:   7,
+	}, &res))
+	a.Equal(res, testResponse{Foo: "k" + since.Format(time.RFC3339), Bar: 307})
+
+	// 业务错误。
+	err := c.Call(ctx, server.POST, "/passport/login", &testLoginRequest{}, &res)
+	a.Assert(server.IsBusinessError(err))
+	a.Equal(server.ErrorCode(err), 100)
+	a.Equal(server.ErrorMessage(err), "go-http: buniness error [code:100] [msg:invalid password]")
+	a.Equal(err.Error(), "go-http: buniness error [code:100] [msg:invalid password]")
+
+	// 参数错误。
+	err = c.Call(ctx, server.POST, "/passport/validate", &testValidateRequest{}, &res)
+	a.Equal(server.ErrorCode(err), 101)
+
+	// 接口不存在时服务返回标准格式的应答。
+	err = c.Call(ctx, server.POST, "/passport/not-found", &testLoginRequest{}, &res)
+	a.Assert(server.IsBusinessError(err))
+	a.Equal(server.ErrorCode(err), server.ErrCodeNotFound)
+}
+
+type testEncodeRequest struct {
+	Page    int       `form:"page,default=1" json:"page"`
+	Name    string    `form:"name,default=foo" json:"name"`
+	Enabled bool      `form:"enabled,default=true" json:"enabled"`
+	Size    *int      `form:"size,default=20" json:"size"`
+	Since   time.Time `form:"since" time_format:"unix" json:"since"`
+	Until   time.Time `form:"until" time_format:"unixnano" json:"until"`
+	Day     time.Time `form:"day" time_format:"2006-01-02 15:04" time_location:"Asia/Shanghai" json:"day"`
+}
+
+func testEncode(ctx context.Context, req *testEncodeRequest) (*testEncodeRequest, error) {
+	return req, nil
+}
+
+func TestClientEncodeQuery(t *testing.T) {
+	a := assert.New(t)
+	s := server.New(&server.Config{})
+	a.NilError(s.AddRoutes(server.RouteList{
+		server.R("/encode", server.GET, testEncode),
+	}))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	c := New(&Config{
+		BaseURL: ts.URL,
+	})
+	ctx := context.Background()
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	until := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	day := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	// 设置了默认值的字段即使是零值也要传递，服务端才不会使用默认值。
+	res := &testEncodeRequest{}
+	a.NilError(c.Call(ctx, server.GET, "/encode", &testEncodeRequest{
+		Since: since,
+		Until: until,
+		Day:   day,
+	}, res))
+	a.Equal(res.Page, 0)
+	a.Equal(res.Name, "")
+	a.Equal(res.Enabled, false)
+	a.Equal(*res.Size, 20)
+	a.Assert(res.Since.Equal(since))
+	a.Assert(res.Until.Equal(until))
+	a.Assert(res.Day.Equal(day))
+
+	size := 0
+	res = &testEncodeRequest{}
+	a.NilError(c.Call(ctx, server.GET, "/encode", &testEncodeRequest{
+		Page: 3,
+		Size: &size,
+	}, res))
+	a.Equal(res.Page, 3)
+	a.Equal(*res.Size, 0)
+	a.Assert(res.Since.IsZero())
+}
+
+func TestClientErrorPassThrough(t *testing.T) {
+	a := assert.New(t)
+	ts := newTestServer()
+	defer ts.Close()
+
+	// 中间服务直接把下游的业务错误返回给上游，错误信息不会被再次包装。
+	downstream := New(&Config{
+		BaseURL: ts.URL,
+	})
+	proxy := server.New(&server.Config{})
+	a.NilError(proxy.AddRoutes(server.RouteList{
+		server.R("/proxy/login", server.POST, func(ctx context.Context, req *testLoginRequest) (*testResponse, error) {
+			res := &testResponse{}
+
+			if err := downstream.Call(ctx, server.POST, "/passport/login", req, res); err != nil {
+				return nil, err
+			}
+
+			return res, nil
+		}),
+	}))
+	ps := httptest.NewServer(proxy.Handler())
+	defer ps.Close()
+
+	c := New(&Config{
+		BaseURL: ps.URL,
+	})
+	err := c.Call(context.Background(), server.POST, "/proxy/login", &testLoginRequest{}, nil)
+	a.Assert(server.IsBusinessError(err))
+	a.Equal(server.ErrorCode(err), 100)
+	a.Equal(err.Error(), "go-http: buniness error [code:100] [msg:invalid password]")
+}
+
+func TestClientTransportError(t *testing.T) {
+	a := assert.New(t)
+	ts := newTestServer()
+	ts.Close()
+
+	c := New(&Config{
+		BaseURL: ts.URL,
+	})
+	err := c.Call(context.Background(), server.POST, "/passport/login", &testLoginRequest{}, nil)
+	a.NonNilError(err)
+	a.Assert(!server.IsBusinessError(err))
+	a.Equal(server.ErrorCode(err), server.ErrCodeTransportError)
+
+	// 没有返回标准格式应答的服务同样是 transport 错误。
+	hs := httptest.NewServer(http.NotFoundHandler())
+	defer hs.Close()
+
+	c = New(&Config{
+		BaseURL: hs.URL,
+	})
+	err = c.Call(context.Background(), server.GET, "/passport/search", nil, nil)
+	a.Assert(!server.IsBusinessError(err))
+	a.Equal(server.ErrorCode(err), server.ErrCodeTransportError)
+}
+
+func TestClientInvalidResponse(t *testing.T) {
+	a := assert.New(t)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/malformed":
+			w.Write([]byte(`{"err":0,"msg":"","data":"not-an-object"}`))
+		case "/large":
+			w.Write([]byte(`{"err":0,"msg":"","data":{"foo":"` + strings.Repeat("x", 1024) + `"}}`))
+		}
+	}))
+	defer hs.Close()
+
+	// data 无法解析同样是 transport 错误。
+	c := New(&Config{
+		BaseURL: hs.URL,
+	})
+	err := c.Call(context.Background(), server.GET, "/malformed", nil, &testResponse{})
+	a.NonNilError(err)
+	a.Assert(!server.IsBusinessError(err))
+	a.Equal(server.ErrorCode(err), server.ErrCodeTransportError)
+
+	// 超过大小限制的应答不会被完整读入内存。
+	c = New(&Config{
+		BaseURL:          hs.URL,
+		MaxResponseBytes: 512,
+	})
+	err = c.Call(context.Background(), server.GET, "/large", nil, &testResponse{})
+	a.NonNilError(err)
+	a.Assert(strings.Contains(err.Error(), "response is too large"))
+	a.Equal(server.ErrorCode(err), server.ErrCodeTransportError)
+
+	// 小于 0 表示不限制大小。
+	c = New(&Config{
+		BaseURL:          hs.URL,
+		MaxResponseBytes: -1,
+	})
+	res := &testResponse{}
+	a.NilError(c.Call(context.Background(), server.GET, "/large", nil, res))
+	a.Equal(len(res.Foo), 1024)
+}
@@ -0,0 +1,281 @@
+// Package clientgen 根据 go-http/server 的路由表生成强类型的 Go client 代码。
+//
+// 由于路由表是一个 Go 变量，生成器需要在引用了路由表的程序里面调用，
+// 一般做法是在项目里写一个小工具，通过 go generate 调用。
+//
+//     func main() {
+//         f, _ := os.Create("client/client_gen.go")
+//         defer f.Close()
+//
+//         clientgen.Generate(f, routes.Routes, &clientgen.Options{
+//             Package: "client",
+//         })
+//     }
+package clientgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/altstory/go-http/server"
+)
+
+const (
+	// DefaultTypeName 是生成的 client 类型的默认名字。
+	DefaultTypeName = "Client"
+
+	clientPkgPath = "github.com/altstory/go-http/client"
+)
+
+// Options 是生成代码的选项。
+type Options struct {
+	Package     string // Package 是生成代码的包名，必须设置。
+	PackagePath string // PackagePath 是生成代码所在包的完整路径，如果请求和应答类型就在这个包里，生成的代码不会 import 这个包。
+	TypeName    string // TypeName 是生成的 client 类型名，默认是 DefaultTypeName。
+}
+
+type method struct {
+	Name     string
+	Method   server.Method
+	Path     string
+	Handler  string
+	Request  string
+	Response string
+}
+
+type generator struct {
+	opts    *Options
+	imports map[string]string // 包路径 -> 别名
+	aliases map[string]bool
+}
+
+// Generate 根据 routes 生成 client 代码并写入 w。
+// 只有业务函数形式的路由会生成方法，方法名就是业务函数的名字，重名的方法会加上数字后缀。
+func Generate(w io.Writer, routes server.Routes, opts *Options) error {
+	if opts == nil || opts.Package == "" {
+		return errors.New("go-http: package name is required to generate client")
+	}
+
+	list, err := server.ListRoutes(routes)
+
+	if err != nil {
+		return err
+	}
+
+	g := &generator{
+		opts:    opts,
+		imports: map[string]string{},
+		aliases: map[string]bool{
+			"context": true,
+			"client":  true,
+			"server":  true,
+		},
+	}
+	methods, err := g.methods(list)
+
+	if err != nil {
+		return err
+	}
+
+	src := g.source(methods)
+	formatted, err := format.Source(src)
+
+	if err != nil {
+		return fmt.Errorf("go-http: fail to format generated code [err:%v]", err)
+	}
+
+	_, err = w.Write(formatted)
+	return err
+}
+
+func (g *generator) methods(routes []*server.RouteInfo) ([]*method, error) {
+	var methods []*method
+	names := map[string]int{}
+
+	for _, route := range routes {
+		if route.Request == nil || route.Response == nil {
+			continue
+		}
+
+		req, err := g.typeName(route.Request)
+
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := g.typeName(route.Response)
+
+		if err != nil {
+			return nil, err
+		}
+
+		name := exportedName(route.Handler, route.Path)
+		names[name]++
+
+		if n := names[name]; n > 1 {
+			name = fmt.Sprintf("%v%v", name, n)
+		}
+
+		m := route.Method
+
+		if m == server.ANY {
+			m = server.POST
+		}
+
+		methods = append(methods, &method{
+			Name:     name,
+			Method:   m,
+			Path:     route.Path,
+			Handler:  route.Handler,
+			Request:  req,
+			Response: res,
+		})
+	}
+
+	return methods, nil
+}
+
+// typeName 返回 t 在生成代码中的名字，并记录需要 import 的包。
+func (g *generator) typeName(t reflect.Type) (string, error) {
+	if t.Name() == "" || t.PkgPath() == "" {
+		return "", fmt.Errorf("go-http: request and response must be named types [type:%v]", t)
+	}
+
+	if t.PkgPath() == g.opts.PackagePath {
+		return t.Name(), nil
+	}
+
+	if !unicode.IsUpper([]rune(t.Name())[0]) {
+		return "", fmt.Errorf("go-http: type is not exported [type:%v]", t)
+	}
+
+	pkg := t.PkgPath()
+	alias, ok := g.imports[pkg]
+
+	if !ok {
+		base := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+				return r
+			}
+
+			return '_'
+		}, path.Base(pkg))
+		alias = base
+
+		for i := 2; g.aliases[alias]; i++ {
+			alias = fmt.Sprintf("%v%v", base, i)
+		}
+
+		g.aliases[alias] = true
+		g.imports[pkg] = alias
+	}
+
+	return alias + "." + t.Name(), nil
+}
+
+func (g *generator) source(methods []*method) []byte {
+	typeName := g.opts.TypeName
+
+	if typeName == "" {
+		typeName = DefaultTypeName
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by go-http/client/clientgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %v\n\n", g.opts.Package)
+	fmt.Fprintf(buf, "import (\n\t\"context\"\n\n\t\"github.com/altstory/go-http/client\"\n")
+
+	pkgs := make([]string, 0, len(g.imports))
+
+	for pkg := range g.imports {
+		pkgs = append(pkgs, pkg)
+	}
+
+	sort.Strings(pkgs)
+
+	for _, pkg := range pkgs {
+		fmt.Fprintf(buf, "\t%v %q\n", g.imports[pkg], pkg)
+	}
+
+	fmt.Fprintf(buf, ")\n\n")
+	fmt.Fprintf(buf, "// %v 是根据路由表生成的 HTTP client。\n", typeName)
+	fmt.Fprintf(buf, "type %v struct {\n\tclient *client.Client\n}\n\n", typeName)
+	fmt.Fprintf(buf, "// New%v 使用 c 创建一个新的 %v。\n", typeName, typeName)
+	fmt.Fprintf(buf, "func New%v(c *client.Client) *%v {\n\treturn &%v{\n\t\tclient: c,\n\t}\n}\n", typeName, typeName, typeName)
+
+	for _, m := range methods {
+		fmt.Fprintf(buf, "\n// %v 调用 %v %v，服务端的处理函数是 %v。\n", m.Name, m.Method, m.Path, m.Handler)
+		fmt.Fprintf(buf, "func (c *%v) %v(ctx context.Context, req *%v) (*%v, error) {\n", typeName, m.Name, m.Request, m.Response)
+		fmt.Fprintf(buf, "\tres := new(%v)\n\n", m.Response)
+		fmt.Fprintf(buf, "\tif err := c.client.Call(ctx, %q, %q, req, res); err != nil {\n", m.Method, m.Path)
+		fmt.Fprintf(buf, "\t\treturn nil, err\n\t}\n\n\treturn res, nil\n}\n")
+	}
+
+	return buf.Bytes()
+}
+
+// exportedName 从处理函数的完整名字中提取函数名，并转换成导出的名字。
+// 如果函数名不能转换成合法的标识符，使用路由路径生成名字。
+func exportedName(handler, routePath string) string {
+	// 方法值的名字形如 pkg.(*Svc).Login-fm，泛型函数的名字形如 pkg.Find[...]。
+	name := strings.TrimSuffix(handler, "-fm")
+	name = strings.TrimSuffix(name, "[...]")
+
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	// 匿名函数的名字形如 func1，没有意义，使用通用名字。
+	if name == "" || strings.HasPrefix(name, "func") {
+		return "Call"
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, name)
+	runes := []rune(name)
+
+	if !unicode.IsLetter(runes[0]) {
+		return pathName(routePath)
+	}
+
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// pathName 使用路由路径生成导出的名字，例如 /user/:id/profile 生成 UserIdProfile。
+func pathName(routePath string) string {
+	buf := &strings.Builder{}
+
+	for _, word := range strings.FieldsFunc(routePath, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(word)
+
+		// 标识符不能以数字开头。
+		if buf.Len() == 0 && !unicode.IsLetter(runes[0]) {
+			continue
+		}
+
+		runes[0] = unicode.ToUpper(runes[0])
+		buf.WriteString(string(runes))
+	}
+
+	if buf.Len() == 0 {
+		return "Call"
+	}
+
+	return buf.String()
+}
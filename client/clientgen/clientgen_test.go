@@ -0,0 +1,122 @@
+package clientgen
+
+import (
+	"bytes"
+	"context"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/altstory/go-http/server"
+	"github.com/huandu/go-assert"
+)
+
+type LoginRequest struct {
+	Username string `json:"username"`
+}
+
+type LoginResponse struct {
+	Token string `json:"token"`
+}
+
+type unexportedRequest struct{}
+
+func Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) { return nil, nil }
+func login(ctx context.Context, req LoginRequest) (LoginResponse, error)   { return LoginResponse{}, nil }
+func bad(ctx context.Context, req *unexportedRequest) (*LoginResponse, error) {
+	return nil, nil
+}
+
+type passportService struct{}
+
+func (*passportService) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	return nil, nil
+}
+
+const pkgPath = "github.com/altstory/go-http/client/clientgen"
+
+func TestGenerate(t *testing.T) {
+	a := assert.New(t)
+	routes := server.RouteMap{
+		"/passport": server.RouteList{
+			server.R("login", server.POST, Login),
+			server.R("login2", server.ANY, login),
+		},
+	}
+	buf := &bytes.Buffer{}
+	a.NilError(Generate(buf, routes, &Options{
+		Package:  "passport",
+		TypeName: "PassportClient",
+	}))
+	src := buf.String()
+
+	_, err := parser.ParseFile(token.NewFileSet(), "client.go", src, 0)
+	a.NilError(err)
+	a.Assert(strings.Contains(src, `clientgen "`+pkgPath+`"`))
+	a.Assert(strings.Contains(src, "func NewPassportClient(c *client.Client) *PassportClient"))
+	a.Assert(strings.Contains(src, "func (c *PassportClient) Login(ctx context.Context, req *clientgen.LoginRequest) (*clientgen.LoginResponse, error)"))
+	a.Assert(strings.Contains(src, `c.client.Call(ctx, "POST", "/passport/login", req, res)`))
+	a.Assert(strings.Contains(src, "func (c *PassportClient) Login2(ctx context.Context, req *clientgen.LoginRequest)"))
+	a.Assert(strings.Contains(src, `c.client.Call(ctx, "POST", "/passport/login2", req, res)`))
+
+	// 生成到类型所在的包里面，不需要 import。
+	buf.Reset()
+	a.NilError(Generate(buf, routes, &Options{
+		Package:     "clientgen",
+		PackagePath: pkgPath,
+	}))
+	src = buf.String()
+	a.Assert(strings.Contains(src, "func (c *Client) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error)"))
+	a.Assert(!strings.Contains(src, `"`+pkgPath+`"`))
+}
+
+func TestGenerateMethodValue(t *testing.T) {
+	a := assert.New(t)
+	svc := &passportService{}
+	routes := server.RouteList{
+		server.R("/passport/login", server.POST, svc.Login),
+	}
+	buf := &bytes.Buffer{}
+	a.NilError(Generate(buf, routes, &Options{
+		Package: "passport",
+	}))
+	src := buf.String()
+
+	_, err := parser.ParseFile(token.NewFileSet(), "client.go", src, 0)
+	a.NilError(err)
+	a.Assert(strings.Contains(src, "func (c *Client) Login(ctx context.Context, req *clientgen.LoginRequest) (*clientgen.LoginResponse, error)"))
+}
+
+func TestExportedName(t *testing.T) {
+	a := assert.New(t)
+	cases := []struct {
+		handler string
+		path    string
+		name    string
+	}{
+		{pkgPath + ".Login", "/login", "Login"},
+		{pkgPath + ".(*passportService).Login-fm", "/login", "Login"},
+		{pkgPath + ".TestGenerate.func1", "/login", "Call"},
+		{pkgPath + ".Find[...]", "/user/find", "Find"},
+		{pkgPath + ".log·in", "/login", "Log_in"},
+		{pkgPath + ".(*passportService).Ⅳ-fm", "/user/:id/login-v2", "UserIdLoginV2"},
+		{pkgPath + ".(*passportService).Ⅳ-fm", "/2fa/verify", "Verify"},
+	}
+
+	for _, c := range cases {
+		a.Use(c)
+		a.Equal(exportedName(c.handler, c.path), c.name)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	a := assert.New(t)
+	buf := &bytes.Buffer{}
+	routes := server.RouteList{
+		server.R("bad", server.POST, bad),
+	}
+
+	a.Assert(Generate(buf, routes, nil) != nil)
+	a.Assert(Generate(buf, routes, &Options{Package: "foo"}) != nil)
+}
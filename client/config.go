@@ -0,0 +1,54 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultTimeout 是默认的请求超时时间。
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultRetryBackoff 是默认的初始重试间隔。
+	DefaultRetryBackoff = 50 * time.Millisecond
+
+	// DefaultRetryMaxBackoff 是默认的最大重试间隔。
+	DefaultRetryMaxBackoff = time.Second
+
+	// DefaultMaxIdleConnsPerHost 是默认的每个 host 最大空闲连接数。
+	DefaultMaxIdleConnsPerHost = 32
+
+	// DefaultIdleConnTimeout 是默认的空闲连接超时时间。
+	DefaultIdleConnTimeout = 90 * time.Second
+
+	// DefaultMaxResponseBytes 是默认的应答 body 最大大小。
+	DefaultMaxResponseBytes = 10 << 20
+)
+
+// Config 是 HTTP client 的配置。
+type Config struct {
+	BaseURL string        `config:"base_url"` // BaseURL 是服务地址，例如 "http://127.0.0.1:8080"。
+	Timeout time.Duration `config:"timeout"`  // Timeout 设置单次请求超时，默认是 DefaultTimeout，每次调用可以通过 WithTimeout 单独设置。
+
+	MaxResponseBytes int64 `config:"max_response_bytes"` // MaxResponseBytes 设置应答 body 的最大大小，默认是 DefaultMaxResponseBytes，小于 0 表示不限制。
+
+	MaxRetries      int           `config:"max_retries"`       // MaxRetries 设置最大重试次数，默认不重试。
+	RetryBackoff    time.Duration `config:"retry_backoff"`     // RetryBackoff 设置初始重试间隔，每次重试间隔翻倍，默认是 DefaultRetryBackoff。
+	RetryMaxBackoff time.Duration `config:"retry_max_backoff"` // RetryMaxBackoff 设置最大重试间隔，默认是 DefaultRetryMaxBackoff。
+	RetryErrCodes   []int         `config:"retry_err_codes"`   // RetryErrCodes 设置需要重试的业务错误码，服务返回这些错误码时无论请求方法是什么都会重试。
+
+	DialTimeout         time.Duration `config:"dial_timeout"`            // DialTimeout 设置建立连接的超时时间，默认与 Timeout 相同。
+	MaxIdleConns        int           `config:"max_idle_conns"`          // MaxIdleConns 设置最大空闲连接数，默认不限制。
+	MaxIdleConnsPerHost int           `config:"max_idle_conns_per_host"` // MaxIdleConnsPerHost 设置每个 host 最大空闲连接数，默认是 DefaultMaxIdleConnsPerHost。
+	MaxConnsPerHost     int           `config:"max_conns_per_host"`      // MaxConnsPerHost 设置每个 host 最大连接数，默认不限制。
+	IdleConnTimeout     time.Duration `config:"idle_conn_timeout"`       // IdleConnTimeout 设置空闲连接超时时间，默认是 DefaultIdleConnTimeout。
+
+	// Transport 设置发送请求使用的 http.RoundTripper，设置之后连接池相关配置都不再生效，一般只在测试中使用。
+	Transport http.RoundTripper `config:"-"`
+
+	BreakerFailures         int           `config:"breaker_failures"`           // BreakerFailures 设置连续失败多少次之后熔断，默认不启用熔断。
+	BreakerOpenTimeout      time.Duration `config:"breaker_open_timeout"`       // BreakerOpenTimeout 设置熔断之后多久进入半开状态，默认是 DefaultBreakerOpenTimeout。
+	BreakerHalfOpenRequests int           `config:"breaker_half_open_requests"` // BreakerHalfOpenRequests 设置半开状态下允许的探测请求数，默认是 DefaultBreakerHalfOpenRequests。
+	BreakerErrCodes         []int         `config:"breaker_err_codes"`          // BreakerErrCodes 设置计入失败次数的业务错误码，默认业务错误都不算失败。
+	MaxConcurrency          int           `config:"max_concurrency"`            // MaxConcurrency 设置每个 host + uri 的最大并发请求数，超过的请求直接返回 ErrBulkheadFull，默认不限制。
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// encodeQuery 将 req 编码成 query 参数。
+// 如果 onlyTagged 为 true，只有设置了 `form` tag 的字段才会被编码。
+// 与 gin 的 form 绑定规则一致，没有设置 tag 的字段使用字段名作为参数名。
+func encodeQuery(req interface{}, onlyTagged bool) (url.Values, error) {
+	query := url.Values{}
+
+	if req == nil {
+		return query, nil
+	}
+
+	v := reflect.ValueOf(req)
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return query, nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("go-http: request must be a struct [type:%v]", v.Type())
+	}
+
+	if err := encodeStruct(query, v, onlyTagged); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func encodeStruct(query url.Values, v reflect.Value, onlyTagged bool) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		tag := field.Tag.Get("form")
+
+		if field.Anonymous && tag == "" {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+
+				fv = fv.Elem()
+			}
+
+			if fv.Kind() == reflect.Struct {
+				if err := encodeStruct(query, fv, onlyTagged); err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if field.PkgPath != "" || tag == "-" || (onlyTagged && tag == "") {
+			continue
+		}
+
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		hasDefault := false
+
+		for _, opt := range opts[1:] {
+			if strings.HasPrefix(opt, "default=") {
+				hasDefault = true
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		// 零值不需要传递，服务端解析的结果是一样的。
+		// 设置了默认值的字段除外，否则服务端会把缺少的参数解析成默认值。
+		if !hasDefault && reflect.DeepEqual(fv.Interface(), reflect.Zero(fv.Type()).Interface()) {
+			continue
+		}
+
+		if err := encodeValue(query, name, fv, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeValue(query url.Values, name string, v reflect.Value, field reflect.StructField) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Type() == typeOfTime {
+		value, err := formatTime(v.Interface().(time.Time), field)
+
+		if err != nil {
+			return err
+		}
+
+		query.Add(name, value)
+		return nil
+	}
+
+	if v.Type().Implements(typeOfTextMarshaler) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+
+		if err != nil {
+			return err
+		}
+
+		query.Add(name, string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeValue(query, name, v.Index(i), field); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		query.Add(name, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		query.Add(name, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		query.Add(name, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		query.Add(name, strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.String:
+		query.Add(name, v.String())
+	default:
+		return fmt.Errorf("go-http: unsupported type in query [name:%v] [type:%v]", name, v.Type())
+	}
+
+	return nil
+}
+
+// formatTime 按照 gin 的绑定规则格式化时间：
+//     - time_format 为 unix 或 unixnano 时，输出秒或者纳秒时间戳；
+//     - 否则按照 time_format 格式化，默认是 RFC3339；
+//     - time_utc 和 time_location 决定服务端解析不带时区的时间时使用的时区，格式化之前需要先转换到这个时区。
+func formatTime(t time.Time, field reflect.StructField) (string, error) {
+	timeFormat := field.Tag.Get("time_format")
+
+	switch strings.ToLower(timeFormat) {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10), nil
+	case "unixnano":
+		return strconv.FormatInt(t.UnixNano(), 10), nil
+	case "":
+		timeFormat = time.RFC3339
+	}
+
+	if utc, _ := strconv.ParseBool(field.Tag.Get("time_utc")); utc {
+		t = t.UTC()
+	}
+
+	if name := field.Tag.Get("time_location"); name != "" {
+		loc, err := time.LoadLocation(name)
+
+		if err != nil {
+			return "", fmt.Errorf("go-http: invalid time location [name:%v] [err:%v]", field.Name, err)
+		}
+
+		t = t.In(loc)
+	}
+
+	return t.Format(timeFormat), nil
+}
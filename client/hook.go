@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/altstory/go-runner"
+)
+
+const defaultSection = "http.client"
+
+var (
+	clientConfigs map[string]*Config
+
+	clientsMu sync.RWMutex
+	clients   = map[string]*Client{}
+)
+
+func init() {
+	runner.LoadConfig(defaultSection, &clientConfigs)
+	runner.AddClient("", func(ctx context.Context) error {
+		clientsMu.Lock()
+		defer clientsMu.Unlock()
+
+		for name, config := range clientConfigs {
+			if config == nil {
+				continue
+			}
+
+			c := New(config)
+			c.name = name
+			clients[name] = c
+		}
+
+		return nil
+	})
+}
+
+// Named 返回配置文件中 `[http.client.<name>]` 对应的 Client，如果没有这个配置则返回 nil。
+//
+// 所有 Client 都会在 go-runner 初始化 client 的阶段创建，
+// 所以这个函数应该在 runner.OnStart 回调或者业务处理函数里面调用，不能在 init 里调用。
+func Named(name string) *Client {
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+	return clients[name]
+}
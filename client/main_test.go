@@ -0,0 +1,11 @@
+package client
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	initMetrics()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/altstory/go-metrics"
+	"github.com/altstory/go-runner"
+)
+
+var (
+	clientMetrics struct {
+		QPS, ProcTime, MaxProcTime, Count, Failure, Retry       *metrics.Metric
+		BreakerOpen, BreakerReject, BulkheadReject, Concurrency *metrics.Metric
+		BreakerTransition                                       *metrics.Metric
+	}
+)
+
+func init() {
+	runner.OnStart(func(ctx context.Context) error {
+		initMetrics()
+		return nil
+	})
+}
+
+func initMetrics() {
+	clientMetrics.QPS = metrics.Define(&metrics.Def{
+		Category: "client_qps",
+		Method:   metrics.Sum,
+		Duration: time.Second,
+	})
+	clientMetrics.ProcTime = metrics.Define(&metrics.Def{
+		Category: "client_proc_time",
+		Method:   metrics.Average,
+	})
+	clientMetrics.MaxProcTime = metrics.Define(&metrics.Def{
+		Category: "client_max_proc_time",
+		Method:   metrics.Maximum,
+	})
+	clientMetrics.Count = metrics.Define(&metrics.Def{
+		Category: "client_count",
+		Method:   metrics.Sum,
+	})
+	clientMetrics.Failure = metrics.Define(&metrics.Def{
+		Category: "client_failure",
+		Method:   metrics.Sum,
+	})
+	clientMetrics.Retry = metrics.Define(&metrics.Def{
+		Category: "client_retry",
+		Method:   metrics.Sum,
+	})
+	clientMetrics.BreakerOpen = metrics.Define(&metrics.Def{
+		Category: "client_breaker_open",
+		Method:   metrics.Sum,
+	})
+	clientMetrics.BreakerReject = metrics.Define(&metrics.Def{
+		Category: "client_breaker_reject",
+		Method:   metrics.Sum,
+	})
+	clientMetrics.BulkheadReject = metrics.Define(&metrics.Def{
+		Category: "client_bulkhead_reject",
+		Method:   metrics.Sum,
+	})
+	clientMetrics.Concurrency = metrics.Define(&metrics.Def{
+		Category: "client_max_concurrency",
+		Method:   metrics.Maximum,
+	})
+	clientMetrics.BreakerTransition = metrics.Define(&metrics.Def{
+		Category: "client_breaker_transition",
+		Method:   metrics.Sum,
+	})
+}
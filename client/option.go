@@ -0,0 +1,44 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// CallOption 是单次调用的选项。
+type CallOption func(opts *callOptions)
+
+type callOptions struct {
+	timeout    time.Duration
+	maxRetries int
+	header     http.Header
+}
+
+// WithTimeout 设置单次调用的超时时间，每次重试都会使用这个超时时间。
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(opts *callOptions) {
+		if timeout > 0 {
+			opts.timeout = timeout
+		}
+	}
+}
+
+// WithMaxRetries 设置单次调用的最大重试次数，设置为 0 表示不重试。
+func WithMaxRetries(maxRetries int) CallOption {
+	return func(opts *callOptions) {
+		if maxRetries >= 0 {
+			opts.maxRetries = maxRetries
+		}
+	}
+}
+
+// WithHeader 为单次调用增加一个 HTTP header。
+func WithHeader(key, value string) CallOption {
+	return func(opts *callOptions) {
+		if opts.header == nil {
+			opts.header = http.Header{}
+		}
+
+		opts.header.Add(key, value)
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/altstory/go-http/server"
+)
+
+// idempotentMethods 是可以安全重试的请求方法。
+var idempotentMethods = map[server.Method]bool{
+	server.GET:     true,
+	server.HEAD:    true,
+	server.OPTIONS: true,
+	server.PUT:     true,
+	server.DELETE:  true,
+}
+
+// attemptResult 是单次请求的结果。
+type attemptResult struct {
+	status   int   // status 是 HTTP 状态码，如果请求没有发出去或者没有收到应答则为 0。
+	business bool  // business 表示 err 是服务返回的业务错误。
+	err      error // err 是请求的错误。
+	rejected bool  // rejected 表示请求被熔断器或者并发限制拒绝，没有发出去。
+}
+
+// shouldRetry 判断请求是否需要重试。
+//
+// 以下情况会重试：
+//     - 服务返回了 Config.RetryErrCodes 中的业务错误码；
+//     - 幂等请求遇到了网络错误，或者服务返回了 502、503、504。
+func (c *Client) shouldRetry(method server.Method, result *attemptResult) bool {
+	if result.err == nil || result.rejected {
+		return false
+	}
+
+	if result.business {
+		return c.retryErrCodes[server.ErrorCode(result.err)]
+	}
+
+	if !idempotentMethods[method] {
+		return false
+	}
+
+	switch result.status {
+	case 0, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// backoff 计算第 attempt 次重试前需要等待的时间，在指数退避的基础上增加随机抖动，避免重试风暴。
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retryBackoff
+
+	for i := 0; i < attempt && d < c.retryMaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > c.retryMaxBackoff {
+		d = c.retryMaxBackoff
+	}
+
+	half := int64(d / 2)
+
+	if half <= 0 {
+		return d
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}
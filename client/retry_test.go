@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/altstory/go-http/server"
+	"github.com/huandu/go-assert"
+)
+
+// newFlakyServer 创建一个测试服务，前 failures 次请求返回 status，之后返回正常应答。
+func newFlakyServer(failures int32, status int, code int) (*httptest.Server, *int32) {
+	var count int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		w.Header().Set("Content-Type", "application/json")
+
+		if n <= failures {
+			w.WriteHeader(status)
+
+			if status == http.StatusOK {
+				fmt.Fprintf(w, `{"err":%v,"msg":"retry"}`, code)
+			}
+
+			return
+		}
+
+		fmt.Fprintf(w, `{"err":0,"msg":"","data":{"foo":"ok","bar":%v}}`, n)
+	}))
+	return ts, &count
+}
+
+func TestClientRetry(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	// 幂等请求遇到 503 会重试。
+	ts, count := newFlakyServer(2, http.StatusServiceUnavailable, 0)
+	defer ts.Close()
+	c := New(&Config{
+		BaseURL:      ts.URL,
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+
+	var res testResponse
+	a.NilError(c.Call(ctx, server.GET, "/flaky", nil, &res))
+	a.Equal(res, testResponse{Foo: "ok", Bar: 3})
+	a.Equal(atomic.LoadInt32(count), int32(3))
+
+	// 非幂等请求不会重试。
+	atomic.StoreInt32(count, 0)
+	err := c.Call(ctx, server.POST, "/flaky", nil, &res)
+	a.Assert(err != nil)
+	a.Equal(atomic.LoadInt32(count), int32(1))
+
+	// 重试次数用完之后返回最后一次的错误。
+	atomic.StoreInt32(count, 0)
+	err = c.Call(ctx, server.GET, "/flaky", nil, &res, WithMaxRetries(1))
+	a.Assert(err != nil)
+	a.Equal(atomic.LoadInt32(count), int32(2))
+}
+
+func TestClientRetryErrCodes(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	ts, count := newFlakyServer(1, http.StatusOK, 42)
+	defer ts.Close()
+
+	c := New(&Config{
+		BaseURL:       ts.URL,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		RetryErrCodes: []int{42},
+	})
+
+	var res testResponse
+	a.NilError(c.Call(ctx, server.POST, "/flaky", nil, &res))
+	a.Equal(res, testResponse{Foo: "ok", Bar: 2})
+	a.Equal(atomic.LoadInt32(count), int32(2))
+
+	// 其他业务错误码不会重试。
+	ts2, count2 := newFlakyServer(1, http.StatusOK, 43)
+	defer ts2.Close()
+	c = New(&Config{
+		BaseURL:       ts2.URL,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		RetryErrCodes: []int{42},
+	})
+	err := c.Call(ctx, server.GET, "/flaky", nil, &res)
+	a.Equal(server.ErrorCode(err), 43)
+	a.Equal(atomic.LoadInt32(count2), int32(1))
+}
+
+func TestClientTimeout(t *testing.T) {
+	a := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	c := New(&Config{
+		BaseURL: ts.URL,
+	})
+	start := time.Now()
+	err := c.Call(context.Background(), server.GET, "/slow", nil, nil, WithTimeout(20*time.Millisecond))
+	a.Assert(err != nil)
+	a.Assert(time.Since(start) < 500*time.Millisecond)
+}
+
+type testTraceRequest struct{}
+
+func TestClientTraceID(t *testing.T) {
+	a := assert.New(t)
+	const traceid = "test-trace-id"
+
+	headers := make(chan http.Header, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		w.Write([]byte(`{"err":0,"msg":"","data":{"foo":"downstream"}}`))
+	}))
+	defer downstream.Close()
+
+	c := New(&Config{
+		BaseURL: downstream.URL,
+	})
+	s := server.New(&server.Config{})
+	s.AddRoutes(server.RouteMap{
+		"/": server.RouteList{
+			server.R("upstream", server.GET, func(ctx context.Context, req *testTraceRequest) (*testResponse, error) {
+				res := new(testResponse)
+				err := c.Call(ctx, server.GET, "/downstream", nil, res, WithHeader("X-Test", "foo"))
+				return res, err
+			}),
+		},
+	})
+	upstream := httptest.NewServer(s.Handler())
+	defer upstream.Close()
+
+	r, err := http.NewRequest(http.MethodGet, upstream.URL+"/upstream", nil)
+	a.NilError(err)
+	r.Header.Set(server.HeaderTraceID, traceid)
+	resp, err := http.DefaultClient.Do(r)
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusOK)
+
+	h := <-headers
+	a.Equal(h.Get(server.HeaderTraceID), traceid)
+	a.Equal(h.Get("X-Test"), "foo")
+}
@@ -1,11 +1,45 @@
 module github.com/altstory/go-http
 
-go 1.12
+go 1.22
 
 require (
 	github.com/altstory/go-log v1.0.5
 	github.com/altstory/go-metrics v1.0.7
 	github.com/altstory/go-runner v1.1.8
 	github.com/gin-gonic/gin v1.6.2
+	github.com/go-playground/validator/v10 v10.2.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/huandu/go-assert v1.1.5
+	golang.org/x/net v0.23.0
+)
+
+require (
+	github.com/aliyun/alibaba-cloud-sdk-go v1.61.229 // indirect
+	github.com/altstory/go-config v1.0.5 // indirect
+	github.com/altstory/go-data v1.1.3 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/go-playground/locales v0.13.0 // indirect
+	github.com/go-playground/universal-translator v0.17.0 // indirect
+	github.com/golang/protobuf v1.3.3 // indirect
+	github.com/huandu/go-clone v1.1.0 // indirect
+	github.com/jmespath/go-jmespath v0.0.0-20180206201540-c2b33e8439af // indirect
+	github.com/json-iterator/go v1.1.9 // indirect
+	github.com/leodido/go-urn v1.2.0 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v0.0.0-20180701023420-4b7aa43c6742 // indirect
+	github.com/pelletier/go-toml v1.6.0 // indirect
+	github.com/tencentcloud/tencentcloud-sdk-go v3.0.133+incompatible // indirect
+	github.com/tidwall/gjson v1.4.0 // indirect
+	github.com/tidwall/match v1.0.1 // indirect
+	github.com/tidwall/pretty v1.0.0 // indirect
+	github.com/ugorji/go/codec v1.1.7 // indirect
+	golang.org/x/crypto v0.21.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/term v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/ini.v1 v1.42.0 // indirect
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0 // indirect
+	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
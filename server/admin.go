@@ -0,0 +1,255 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-log"
+	"github.com/altstory/go-runner"
+)
+
+const maskedSecret = "******"
+
+var (
+	logConfig       *log.Config
+	logConfigLoaded bool
+	logLevelMu      sync.Mutex
+)
+
+func init() {
+	// 修改日志级别需要使用 go-runner 初始化日志时的配置，否则会丢失日志路径等设置。
+	runner.LoadConfig("log", &logConfig)
+	runner.OnStart(func(ctx context.Context) error {
+		logConfigLoaded = true
+		return nil
+	})
+}
+
+type adminRoute struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Handler     string   `json:"handler"`
+	Request     string   `json:"request,omitempty"`
+	Response    string   `json:"response,omitempty"`
+	Middlewares []string `json:"middlewares,omitempty"`
+}
+
+// newAdminHandler 创建管理接口，所有接口都挂在 prefix 之下。
+//
+// 管理接口包括：
+//     - GET  {prefix}/routes：列出所有已注册的路由；
+//     - GET  {prefix}/config：输出当前生效的配置，敏感信息会被隐藏；
+//     - GET  {prefix}/build_info：输出编译信息和 go-runner 的 meta 信息；
+//     - GET  {prefix}/goroutines：输出所有 goroutine 的调用栈；
+//     - GET  {prefix}/log_level：输出当前日志级别，使用 POST 并设置 level 参数可以修改日志级别；
+//     - GET  {prefix}/pprof/：net/http/pprof 提供的所有接口。
+func newAdminHandler(s *Server, prefix string, token string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(prefix+"/routes", func(w http.ResponseWriter, r *http.Request) {
+		routes := s.Routes()
+		list := make([]*adminRoute, 0, len(routes))
+
+		for _, info := range routes {
+			ar := &adminRoute{
+				Method:      info.Method.String(),
+				Path:        info.Path,
+				Handler:     info.Handler,
+				Middlewares: info.Middlewares,
+			}
+
+			if info.Request != nil {
+				ar.Request = info.Request.String()
+			}
+
+			if info.Response != nil {
+				ar.Response = info.Response.String()
+			}
+
+			list = append(list, ar)
+		}
+
+		writeAdminJSON(w, http.StatusOK, list)
+	})
+	mux.HandleFunc(prefix+"/config", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, maskConfig(&s.config))
+	})
+	mux.HandleFunc(prefix+"/build_info", func(w http.ResponseWriter, r *http.Request) {
+		bi, _ := debug.ReadBuildInfo()
+		writeAdminJSON(w, http.StatusOK, map[string]interface{}{
+			"go_version": runtime.Version(),
+			"build":      bi,
+			"meta":       runner.Meta(),
+		})
+	})
+	mux.HandleFunc(prefix+"/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(goroutineStacks())
+	})
+	mux.HandleFunc(prefix+"/log_level", handleLogLevel)
+
+	// net/http/pprof 的 Index 只能识别 /debug/pprof/ 前缀，需要改写路径。
+	pprofPrefix := prefix + "/pprof/"
+	mux.HandleFunc(pprofPrefix, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, pprofPrefix)
+
+		switch name {
+		case "cmdline":
+			pprof.Cmdline(w, r)
+		case "profile":
+			pprof.Profile(w, r)
+		case "symbol":
+			pprof.Symbol(w, r)
+		case "trace":
+			pprof.Trace(w, r)
+		default:
+			r2 := new(http.Request)
+			*r2 = *r
+			u := *r.URL
+			u.Path = "/debug/pprof/" + name
+			r2.URL = &u
+			pprof.Index(w, r2)
+		}
+	})
+
+	if token == "" {
+		return mux
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 只接受 Authorization header 中的 token，放在 URL 里的 token 会被记录到日志和流量录制里。
+		auth := r.Header.Get("Authorization")
+		actual := strings.TrimPrefix(auth, "Bearer ")
+
+		if actual == auth || subtle.ConstantTimeCompare([]byte(actual), []byte(token)) != 1 {
+			writeAdminJSON(w, http.StatusUnauthorized, map[string]string{
+				"error": "go-http: invalid admin token",
+			})
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		level := log.DefaultLogLevel
+
+		if logConfig != nil && logConfig.LogLevel != "" {
+			level = logConfig.LogLevel
+		}
+
+		writeAdminJSON(w, http.StatusOK, map[string]string{
+			"level": level,
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		writeAdminJSON(w, http.StatusMethodNotAllowed, map[string]string{
+			"error": "go-http: method is not allowed",
+		})
+		return
+	}
+
+	level := r.FormValue("level")
+
+	switch strings.ToLower(level) {
+	case "debug", "info", "trace", "warn", "warning", "error", "fatal":
+	default:
+		writeAdminJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "go-http: invalid log level",
+		})
+		return
+	}
+
+	if !logConfigLoaded {
+		writeAdminJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "go-http: log is not initialized by go-runner",
+		})
+		return
+	}
+
+	var c log.Config
+
+	if logConfig != nil {
+		c = *logConfig
+	}
+
+	c.LogLevel = level
+	log.Init(&c)
+	logConfig = &c
+
+	log.Warnf(context.Background(), "level=%v||go-http: log level is changed by admin", level)
+	writeAdminJSON(w, http.StatusOK, map[string]string{
+		"level": level,
+	})
+}
+
+func goroutineStacks() []byte {
+	buf := make([]byte, 1<<16)
+
+	for {
+		n := runtime.Stack(buf, true)
+
+		if n < len(buf) {
+			return buf[:n]
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// maskConfig 将 config 转换成以配置名为 key 的 map，所有标记了 `secret:"true"` 的非空字段都会被隐藏。
+func maskConfig(config interface{}) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	t := v.Type()
+	m := make(map[string]interface{}, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("config"), ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		if field.Tag.Get("secret") == "true" && !reflect.DeepEqual(fv.Interface(), reflect.Zero(fv.Type()).Interface()) {
+			m[name] = maskedSecret
+			continue
+		}
+
+		if d, ok := fv.Interface().(time.Duration); ok {
+			m[name] = d.String()
+			continue
+		}
+
+		m[name] = fv.Interface()
+	}
+
+	return m
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestAdminHandler(t *testing.T) {
+	a := assert.New(t)
+	const token = "admin-token"
+	s := New(&Config{
+		AdminURI:   "/debug/admin",
+		AdminToken: token,
+	})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/passport": RouteList{
+			R("login", POST, testLogin),
+		},
+	}))
+	testServer := httptest.NewServer(s.Handler())
+	defer testServer.Close()
+	prefix := testServer.URL + "/debug/admin"
+
+	get := func(uri string, v interface{}) int {
+		req, _ := http.NewRequest(http.MethodGet, prefix+uri, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		a.NilError(err)
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+
+		if v != nil {
+			a.NilError(json.Unmarshal(data, v))
+		}
+
+		return resp.StatusCode
+	}
+
+	// 没有 token 不能访问。
+	resp, err := http.Get(prefix + "/routes")
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusUnauthorized)
+
+	// token 只能放在 Authorization header 里。
+	resp, err = http.Get(prefix + "/routes?token=" + token)
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusUnauthorized)
+
+	var routes []*adminRoute
+	a.Equal(get("/routes", &routes), http.StatusOK)
+	a.Equal(len(routes), 1)
+	a.Equal(routes[0].Method, "POST")
+	a.Equal(routes[0].Path, "/passport/login")
+	a.Equal(routes[0].Handler, "github.com/altstory/go-http/server.testLogin")
+	a.Equal(routes[0].Request, "server.testLoginRequest")
+	a.Equal(routes[0].Response, "server.testCommonResponse")
+
+	var config map[string]interface{}
+	a.Equal(get("/config", &config), http.StatusOK)
+	a.Equal(config["admin_token"], maskedSecret)
+	a.Equal(config["admin_uri"], "/debug/admin")
+	a.Equal(config["shutdown_timeout"], DefaultShutdownTimeout.String())
+
+	var buildInfo map[string]interface{}
+	a.Equal(get("/build_info", &buildInfo), http.StatusOK)
+	a.Assert(buildInfo["go_version"] != "")
+
+	a.Equal(get("/goroutines", nil), http.StatusOK)
+	a.Equal(get("/pprof/", nil), http.StatusOK)
+	a.Equal(get("/pprof/goroutine?debug=1", nil), http.StatusOK)
+
+	var level map[string]string
+	a.Equal(get("/log_level", &level), http.StatusOK)
+	a.Assert(level["level"] != "")
+
+	// 测试中日志没有经过 go-runner 初始化，不能修改日志级别。
+	req, _ := http.NewRequest(http.MethodPost, prefix+"/log_level", strings.NewReader(url.Values{
+		"level": {"debug"},
+	}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = http.DefaultClient.Do(req)
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusServiceUnavailable)
+}
+
+func TestAdminHandlerWithoutToken(t *testing.T) {
+	a := assert.New(t)
+
+	// 与业务接口共用地址的管理接口必须设置 token。
+	s := New(&Config{
+		AdminURI: "/debug/admin",
+	})
+	a.NonNilError(s.Serve())
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/admin/config", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusNotFound)
+
+	// 单独监听地址的管理接口可以不设置 token。
+	s = New(&Config{
+		AdminURI:  "/debug/admin",
+		AdminAddr: "127.0.0.1:0",
+	})
+	a.NilError(s.err)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// bindingPlan 是根据业务函数的请求类型预先计算好的参数解析方案，避免在每次请求时重复分析类型。
+type bindingPlan struct {
+	typ         reflect.Type // typ 是请求的结构类型。
+	indirect    bool         // indirect 表示业务函数接收的是 *typ。
+	alwaysQuery bool         // alwaysQuery 表示请求结构中有默认值或校验规则，即使没有 query 也需要解析。
+	pool        *sync.Pool   // pool 缓存请求结构，只有业务函数按值接收请求时才会设置。
+}
+
+// newBindingPlan 根据业务函数的请求参数类型 in 生成解析方案，in 是结构或者结构指针。
+func newBindingPlan(in reflect.Type) *bindingPlan {
+	typ := in
+	indirect := false
+
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		indirect = true
+	}
+
+	plan := &bindingPlan{
+		typ:         typ,
+		indirect:    indirect,
+		alwaysQuery: needsQueryBinding(typ, map[reflect.Type]bool{}),
+	}
+
+	// 业务函数按值接收请求时，框架传入的是请求结构的拷贝，调用结束后可以复用原来的结构。
+	// 按指针接收时业务函数可能会持有这个指针，不能复用。
+	if !indirect {
+		plan.pool = &sync.Pool{
+			New: func() interface{} {
+				return reflect.New(typ).Interface()
+			},
+		}
+	}
+
+	return plan
+}
+
+// needsQueryBinding 判断 t 中是否有设置了默认值的 form tag 或者 binding 校验规则。
+func needsQueryBinding(t reflect.Type, visited map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || visited[t] {
+		return false
+	}
+
+	visited[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if strings.Contains(field.Tag.Get("form"), "default=") || field.Tag.Get("binding") != "" {
+			return true
+		}
+
+		if needsQueryBinding(field.Type, visited) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// acquire 返回一个指向空请求结构的指针。
+func (plan *bindingPlan) acquire() reflect.Value {
+	if plan.pool == nil {
+		return reflect.New(plan.typ)
+	}
+
+	return reflect.ValueOf(plan.pool.Get())
+}
+
+// release 清空并归还 acquire 返回的请求结构。
+func (plan *bindingPlan) release(ptr reflect.Value) {
+	if plan.pool == nil {
+		return
+	}
+
+	ptr.Elem().Set(reflect.Zero(plan.typ))
+	plan.pool.Put(ptr.Interface())
+}
+
+// arg 返回业务函数需要的参数值。
+func (plan *bindingPlan) arg(ptr reflect.Value) reflect.Value {
+	if plan.indirect {
+		return ptr
+	}
+
+	return ptr.Elem()
+}
+
+// bindRequest 按照业务函数的规则解析请求参数：
+//     - 所有请求都会解析 query；
+//     - 非 GET 请求且 Content-Type 是 JSON 时，还会解析 body。
+//
+// 返回的是业务函数需要的参数值。
+func bindRequest(c *httpContext, plan *bindingPlan) (reflect.Value, *errorMsg) {
+	ptr := reflect.New(plan.typ)
+
+	if em := plan.bind(c, ptr.Interface()); em != nil {
+		return plan.arg(ptr), em
+	}
+
+	return plan.arg(ptr), nil
+}
+
+// bind 按照业务函数的规则将请求参数解析到 ptr 里，ptr 必须是指向 plan.typ 的指针。
+func (plan *bindingPlan) bind(c *httpContext, ptr interface{}) *errorMsg {
+	// 没有 query 时，解析 query 不会改变请求结构，可以跳过。
+	if c.Request.URL.RawQuery != "" || plan.alwaysQuery {
+		if err := c.BindQuery(ptr); err != nil {
+			return newErrorMsg(ErrCodeBadRequest, fmt.Sprintf("go-http: fail to parse query with error: %v", err))
+		}
+	}
+
+	if c.Request.Method != http.MethodGet && c.ContentType() == mimeJSON {
+		if err := c.BindJSON(ptr); err != nil {
+			if em := bodyErrorMsg(err); em != nil {
+				return em
+			}
+
+			return newErrorMsg(ErrCodeBadRequest, fmt.Sprintf("go-http: invalid request content type or invalid JSON in body with error: %v", err))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testBindingRequest struct {
+	UID   int64    `form:"uid" json:"uid"`
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Limit int      `form:"limit"`
+}
+
+type testBindingDefaultRequest struct {
+	testBindingEmbedded
+	Name string `json:"name"`
+}
+
+type testBindingEmbedded struct {
+	Limit int `form:"limit,default=10"`
+}
+
+type testBindingRequiredRequest struct {
+	Inner *struct {
+		ID int64 `json:"id" binding:"required"`
+	} `json:"inner"`
+}
+
+func TestBindingPlan(t *testing.T) {
+	a := assert.New(t)
+
+	plan := newBindingPlan(reflect.TypeOf(&testBindingRequest{}))
+	a.Equal(plan.typ, reflect.TypeOf(testBindingRequest{}))
+	a.Assert(plan.indirect)
+	a.Assert(!plan.alwaysQuery)
+	a.Assert(plan.pool == nil)
+
+	plan = newBindingPlan(reflect.TypeOf(testBindingDefaultRequest{}))
+	a.Assert(!plan.indirect)
+	a.Assert(plan.alwaysQuery)
+	a.Assert(plan.pool != nil)
+
+	plan = newBindingPlan(reflect.TypeOf(testBindingRequiredRequest{}))
+	a.Assert(plan.alwaysQuery)
+
+	// 复用的请求结构必须是空的。
+	plan = newBindingPlan(reflect.TypeOf(testBindingRequest{}))
+	ptr := plan.acquire()
+	req := ptr.Interface().(*testBindingRequest)
+	req.UID = 12
+	req.Tags = []string{"a"}
+	plan.release(ptr)
+
+	for i := 0; i < 10; i++ {
+		ptr = plan.acquire()
+		a.Equal(ptr.Interface(), &testBindingRequest{})
+		plan.release(ptr)
+	}
+}
+
+func testBindingByValue(ctx context.Context, req testBindingRequest) (*testBindingRequest, error) {
+	return &req, nil
+}
+
+func testBindingDefault(ctx context.Context, req testBindingDefaultRequest) (*testBindingDefaultRequest, error) {
+	return &req, nil
+}
+
+func TestBindingByValue(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+	a.NilError(s.AddRoutes(RouteList{
+		R("value", POST, testBindingByValue),
+		R("default", POST, testBindingDefault),
+	}))
+
+	call := func(uri, body string) string {
+		r := httptest.NewRequest(http.MethodPost, uri, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		a.Equal(w.Code, http.StatusOK)
+
+		body = w.Body.String()
+		return body[:strings.Index(body, `,"now"`)]
+	}
+
+	a.Equal(call("/value?uid=12", `{"name":"foo","tags":["a","b"]}`), `{"data":{"uid":12,"name":"foo","tags":["a","b"],"Limit":0},"err":0`)
+
+	// 复用请求结构时不能残留上一次请求的数据。
+	a.Equal(call("/value", `{"name":"bar"}`), `{"data":{"uid":0,"name":"bar","tags":null,"Limit":0},"err":0`)
+
+	// 没有 query 也要设置默认值。
+	a.Equal(call("/default", `{"name":"foo"}`), `{"data":{"Limit":10,"name":"foo"},"err":0`)
+	a.Equal(call("/default?limit=20", `{}`), `{"data":{"Limit":20,"name":""},"err":0`)
+}
+
+func newBenchmarkServer(b *testing.B, engine string) *Server {
+	s := New(&Config{
+		Engine: engine,
+	})
+
+	if err := s.AddRoutes(RouteList{
+		R("pointer", POST, func(ctx context.Context, req *testBindingRequest) (*testCommonResponse, error) {
+			return newTestCommonResponse(), nil
+		}),
+		R("value", POST, func(ctx context.Context, req testBindingRequest) (*testCommonResponse, error) {
+			return newTestCommonResponse(), nil
+		}),
+		Handle("typed", POST, func(ctx context.Context, req *testBindingRequest) (*testCommonResponse, error) {
+			return newTestCommonResponse(), nil
+		}),
+	}); err != nil {
+		b.Fatal(err)
+	}
+
+	return s
+}
+
+func benchmarkBusinessHandler(b *testing.B, uri string) {
+	benchmarkEngine(b, "", uri)
+}
+
+func benchmarkEngine(b *testing.B, engine, uri string) {
+	s := newBenchmarkServer(b, engine)
+	h := s.Handler()
+	body := `{"name":"foo","tags":["a","b","c"]}`
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest(http.MethodPost, uri, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			b.Fatalf("unexpected status %v", w.Code)
+		}
+	}
+}
+
+func BenchmarkBusinessHandlerPointer(b *testing.B) {
+	benchmarkBusinessHandler(b, "/pointer")
+}
+
+func BenchmarkBusinessHandlerPointerWithQuery(b *testing.B) {
+	benchmarkBusinessHandler(b, "/pointer?uid=12&limit=10")
+}
+
+func BenchmarkBusinessHandlerValue(b *testing.B) {
+	benchmarkBusinessHandler(b, "/value")
+}
+
+func BenchmarkBusinessHandlerTyped(b *testing.B) {
+	benchmarkBusinessHandler(b, "/typed")
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultBodyReadGracePeriod 是开始读取请求 body 之后默认不检查读取速率的时间。
+const DefaultBodyReadGracePeriod = time.Second
+
+var errBodyReadTooSlow = errors.New("go-http: request body is read too slowly")
+
+// MaxBodyBytes 修改这条路由的请求 body 最大大小，覆盖 Config 中的 MaxBodyBytes，n 小于 0 表示不限制。
+func (r *Route) MaxBodyBytes(n int64) *Route {
+	handlers := make([]Handler, 0, len(r.Handlers)+1)
+	handlers = append(handlers, &bodyLimit{limit: n})
+	r.Handlers = append(handlers, r.Handlers...)
+	return r
+}
+
+// bodyLimit 是修改请求 body 最大大小的中间件。
+type bodyLimit struct {
+	limit int64
+}
+
+func (bl *bodyLimit) parse() (handlerFunc, error) {
+	return func(c *httpContext) {
+		if c.body != nil {
+			c.body.limit = bl.limit
+		}
+	}, nil
+}
+
+// limitBody 限制 h 处理的所有请求的 body 大小和读取速率。
+//
+// 设置了 MinBodyReadRate 之后，读完前 n 个字节的时间不能超过 BodyReadGracePeriod + n/MinBodyReadRate 秒，
+// 否则框架会中断读取并返回 408。与 ReadTimeout 不同，这个限制与 body 的大小成正比，
+// 既不会误伤正常上传的大文件，也能尽早断开故意缓慢发送 body 的连接。
+// 检查速率需要修改连接的读超时，修改后的读超时不会晚于开始处理请求之后 ReadTimeout 的时间。
+//
+// Content-Length 超过大小限制的请求不会读取 body，第一次读取时就会返回 *http.MaxBytesError。
+func limitBody(h http.Handler, config *Config) http.Handler {
+	limit := config.MaxBodyBytes
+
+	// limitedBody 使用负数表示不限制。
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rate := config.MinBodyReadRate
+	grace := config.BodyReadGracePeriod
+	readTimeout := config.ReadTimeout
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			var timeout time.Time
+
+			if readTimeout > 0 {
+				timeout = time.Now().Add(readTimeout)
+			}
+
+			r.Body = &limitedBody{
+				ReadCloser: r.Body,
+				w:          w,
+				size:       r.ContentLength,
+				limit:      limit,
+				rate:       rate,
+				grace:      grace,
+				timeout:    timeout,
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// limitedBody 限制请求 body 的大小和读取速率。
+type limitedBody struct {
+	io.ReadCloser
+
+	w       http.ResponseWriter
+	size    int64 // size 是请求的 Content-Length，-1 表示未知。
+	limit   int64
+	rate    int64
+	grace   time.Duration
+	timeout time.Time // timeout 是 ReadTimeout 对应的读超时，零值表示不限制。
+
+	read     int64
+	start    time.Time
+	deadline bool
+	err      error
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	// Content-Length 已经超过了大小限制，不需要读取 body。
+	if b.limit >= 0 && b.size > b.limit {
+		return 0, b.fail(&http.MaxBytesError{Limit: b.limit})
+	}
+
+	// 修改限制之前可能已经读了更多的内容，例如外层中间件读取 body 之后，路由设置了更小的限制。
+	if b.limit >= 0 && b.read > b.limit {
+		return 0, b.fail(&http.MaxBytesError{Limit: b.limit})
+	}
+
+	// 多读一个字节，用来判断 body 是否超过了大小限制。
+	if b.limit >= 0 && int64(len(p)) > b.limit-b.read+1 {
+		p = p[:b.limit-b.read+1]
+	}
+
+	if b.rate > 0 {
+		if b.start.IsZero() {
+			b.start = time.Now()
+		}
+
+		// 下一个字节必须在 deadline 之前读到，否则说明读取速率太慢了。
+		// deadline 会覆盖 http.Server 设置的读超时，所以不能晚于 ReadTimeout 对应的时间。
+		deadline := b.start.Add(b.allowed(b.read + 1))
+
+		if !b.timeout.IsZero() && b.timeout.Before(deadline) {
+			deadline = b.timeout
+		}
+
+		if http.NewResponseController(b.w).SetReadDeadline(deadline) == nil {
+			b.deadline = true
+		}
+	}
+
+	n, err = b.ReadCloser.Read(p)
+	b.read += int64(n)
+
+	if b.limit >= 0 && b.read > b.limit {
+		n -= int(b.read - b.limit)
+		b.read = b.limit
+		return n, b.fail(&http.MaxBytesError{Limit: b.limit})
+	}
+
+	if b.rate > 0 {
+		if b.deadline && errors.Is(err, os.ErrDeadlineExceeded) {
+			return n, b.fail(errBodyReadTooSlow)
+		}
+
+		if err == nil && time.Since(b.start) > b.allowed(b.read) {
+			return n, b.fail(errBodyReadTooSlow)
+		}
+	}
+
+	// body 已经读完，需要取消 deadline，否则之后检测连接是否断开的后台读取会超时。
+	if err != nil && b.deadline {
+		http.NewResponseController(b.w).SetReadDeadline(time.Time{})
+		b.deadline = false
+	}
+
+	return n, err
+}
+
+// allowed 返回读完前 n 个字节最多可以花费的时间。
+func (b *limitedBody) allowed(n int64) time.Duration {
+	return b.grace + time.Duration(float64(n)/float64(b.rate)*float64(time.Second))
+}
+
+// fail 记录错误，之后的读取都会返回这个错误，并且在应答之后关闭连接，不再读取剩下的 body。
+func (b *limitedBody) fail(err error) error {
+	b.err = err
+	b.w.Header().Set("Connection", "close")
+	return err
+}
+
+// bodyErrorMsg 将读取 body 时发生的错误转换成对应的错误码，如果不是 limitedBody 的错误则返回 nil。
+func bodyErrorMsg(err error) *errorMsg {
+	var mbe *http.MaxBytesError
+
+	if errors.As(err, &mbe) {
+		return newErrorMsg(ErrCodePayloadTooLarge, fmt.Sprintf("go-http: request body is too large [limit:%v]", mbe.Limit))
+	}
+
+	if errors.Is(err, errBodyReadTooSlow) {
+		return newErrorMsg(ErrCodeRequestTimeout, errBodyReadTooSlow.Error())
+	}
+
+	return nil
+}
+
+// bindStatus 返回解析请求参数失败时应答的状态码。
+func bindStatus(em *errorMsg) int {
+	switch em.code {
+	case ErrCodePayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case ErrCodeRequestTimeout:
+		return http.StatusRequestTimeout
+	}
+
+	return http.StatusBadRequest
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testBodyRequest struct {
+	Name string `json:"name"`
+}
+
+func testBodyHandler(ctx context.Context, req *testBodyRequest) (*testBodyRequest, error) {
+	return req, nil
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	a := assert.New(t)
+
+	for _, engine := range testEngines {
+		a.Use(engine)
+		s := New(&Config{
+			Engine:       engine,
+			MaxBodyBytes: 32,
+		})
+		a.NilError(s.AddRoutes(RouteList{
+			R("/default", POST, testBodyHandler),
+			R("/large", POST, testBodyHandler).MaxBodyBytes(1024),
+			R("/unlimited", POST, testBodyHandler).MaxBodyBytes(-1),
+			R("/raw", POST, func(w http.ResponseWriter, r *http.Request) {
+				_, err := ioutil.ReadAll(r.Body)
+				var mbe *http.MaxBytesError
+
+				if errors.As(err, &mbe) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+				}
+			}),
+		}))
+
+		call := func(uri string, size int) (*httptest.ResponseRecorder, map[string]interface{}) {
+			body := `{"name":"` + strings.Repeat("x", size) + `"}`
+			r := httptest.NewRequest(http.MethodPost, uri, strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+			res := map[string]interface{}{}
+			json.Unmarshal(w.Body.Bytes(), &res)
+			return w, res
+		}
+
+		w, res := call("/default", 10)
+		a.Equal(w.Code, http.StatusOK)
+		a.Equal(res["err"], float64(ErrCodeOK))
+
+		w, res = call("/default", 100)
+		a.Equal(w.Code, http.StatusRequestEntityTooLarge)
+		a.Equal(res["err"], float64(ErrCodePayloadTooLarge))
+		a.Equal(w.Header().Get("Connection"), "close")
+
+		w, _ = call("/large", 100)
+		a.Equal(w.Code, http.StatusOK)
+
+		w, res = call("/large", 2000)
+		a.Equal(w.Code, http.StatusRequestEntityTooLarge)
+		a.Equal(res["err"], float64(ErrCodePayloadTooLarge))
+
+		w, _ = call("/unlimited", 2000)
+		a.Equal(w.Code, http.StatusOK)
+
+		w, _ = call("/raw", 10)
+		a.Equal(w.Code, http.StatusOK)
+
+		w, _ = call("/raw", 100)
+		a.Equal(w.Code, http.StatusRequestEntityTooLarge)
+
+		// Content-Length 超过限制时不读取 body。
+		r := httptest.NewRequest(http.MethodPost, "/default", ioutil.NopCloser(testUnreadableBody{}))
+		r.Header.Set("Content-Type", "application/json")
+		r.ContentLength = 100
+		w = httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		a.Equal(w.Code, http.StatusRequestEntityTooLarge)
+		a.Equal(w.Header().Get("Connection"), "close")
+	}
+}
+
+// testUnreadableBody 在被读取时返回错误。
+type testUnreadableBody struct{}
+
+func (testUnreadableBody) Read(p []byte) (int, error) {
+	return 0, errors.New("body should not be read")
+}
+
+func TestMinBodyReadRate(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		MinBodyReadRate:     1000,
+		BodyReadGracePeriod: 50 * time.Millisecond,
+	})
+	a.NilError(s.AddRoutes(RouteList{
+		R("/slow", POST, testBodyHandler),
+	}))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	// 正常速度的请求不受影响。
+	resp, err := http.Post(ts.URL+"/slow", "application/json", strings.NewReader(`{"name":"foo"}`))
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusOK)
+
+	// 发送一部分 body 之后停止发送，服务应该在 BodyReadGracePeriod 之后很快断开。
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	a.NilError(err)
+	defer conn.Close()
+
+	start := time.Now()
+	_, err = conn.Write([]byte("POST /slow HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: 100000\r\n\r\n{\"name\":\""))
+	a.NilError(err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	resp, err = http.ReadResponse(bufio.NewReader(conn), nil)
+	a.NilError(err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	res := map[string]interface{}{}
+	a.NilError(json.Unmarshal(body, &res))
+	a.Equal(resp.StatusCode, http.StatusRequestTimeout)
+	a.Equal(res["err"], float64(ErrCodeRequestTimeout))
+	a.Assert(time.Since(start) < time.Second)
+}
+
+func TestMinBodyReadRateWithReadTimeout(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		ReadTimeout:     300 * time.Millisecond,
+		MinBodyReadRate: 1,
+	})
+	a.NilError(s.AddRoutes(RouteList{
+		R("/slow", POST, testBodyHandler),
+	}))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	// 每个字节都满足最低速率，但是总时间超过了 ReadTimeout。
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	a.NilError(err)
+	defer conn.Close()
+
+	start := time.Now()
+	_, err = conn.Write([]byte("POST /slow HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: 10\r\n\r\n"))
+	a.NilError(err)
+
+	go func() {
+		for _, c := range []byte(`{"name":1}`) {
+			time.Sleep(100 * time.Millisecond)
+
+			if _, err := conn.Write([]byte{c}); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusRequestTimeout)
+	a.Assert(time.Since(start) < 800*time.Millisecond)
+}
+
+func TestMaxBodyBytesWithCapture(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-http-test")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	s := New(&Config{
+		CaptureFile: filepath.Join(dir, "capture.jsonl"),
+	})
+	a.NilError(s.AddRoutes(RouteList{
+		R("/small", POST, testBodyHandler).MaxBodyBytes(100),
+		R("/raw", POST, func(w http.ResponseWriter, r *http.Request) {
+			_, err := ioutil.ReadAll(r.Body)
+			var mbe *http.MaxBytesError
+
+			if errors.As(err, &mbe) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+			}
+		}).MaxBodyBytes(10),
+	}))
+	defer s.Shutdown(context.Background())
+
+	// 不带 Content-Length 的请求，录制流量时同样受到路由的限制。
+	for _, size := range []int{200, 100 << 10} {
+		a.Use(size)
+		body := `{"name":"` + strings.Repeat("x", size) + `"}`
+		r := httptest.NewRequest(http.MethodPost, "/small", ioutil.NopCloser(strings.NewReader(body)))
+		r.Header.Set("Content-Type", "application/json")
+		r.ContentLength = -1
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		a.Equal(w.Code, http.StatusRequestEntityTooLarge)
+
+		r = httptest.NewRequest(http.MethodPost, "/raw", ioutil.NopCloser(strings.NewReader(body)))
+		r.ContentLength = -1
+		w = httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		a.Equal(w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestLimitedBodyReadBeyondLimit(t *testing.T) {
+	a := assert.New(t)
+	b := &limitedBody{
+		ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 100))),
+		w:          httptest.NewRecorder(),
+		size:       -1,
+		limit:      -1,
+	}
+	buf := make([]byte, 50)
+	n, err := b.Read(buf)
+	a.NilError(err)
+	a.Equal(n, 50)
+
+	// 读取之后才设置了更小的限制。
+	b.limit = 10
+	_, err = b.Read(buf)
+	var mbe *http.MaxBytesError
+	a.Assert(errors.As(err, &mbe))
+	a.Equal(mbe.Limit, int64(10))
+}
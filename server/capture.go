@@ -0,0 +1,340 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+// DefaultCaptureMaxBodyBytes 是录制流量时默认记录的最大 body 大小。
+const DefaultCaptureMaxBodyBytes = 64 << 10
+
+// captureQueueSize 是等待写入录制文件的最大记录数，超过之后新的记录会被丢弃。
+const captureQueueSize = 1024
+
+var (
+	// capturedSecretHeaders 是录制时会被删除的敏感 header。
+	capturedSecretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+	// capturedSecretParams 是录制时会被隐藏的敏感 query 参数。
+	capturedSecretParams = []string{"token", "access_token"}
+)
+
+// CaptureRecord 是一条录制的请求和应答，录制文件中每行是一个 JSON 编码的 CaptureRecord。
+type CaptureRecord struct {
+	Time     time.Time        `json:"time"`     // Time 是请求开始的时间。
+	Request  *CaptureRequest  `json:"request"`  // Request 是请求的内容。
+	Response *CaptureResponse `json:"response"` // Response 是应答的内容。
+}
+
+// CaptureRequest 是录制的请求。
+type CaptureRequest struct {
+	Method    string      `json:"method"`              // Method 是请求方法。
+	Route     string      `json:"route"`               // Route 是匹配到的路由，例如 /user/:id，没有匹配到路由时为空。
+	URL       string      `json:"url"`                 // URL 是请求的 path 和 query。
+	Header    http.Header `json:"header"`              // Header 是请求 header，敏感 header 会被删除。
+	Body      string      `json:"body,omitempty"`      // Body 是请求 body。
+	Truncated bool        `json:"truncated,omitempty"` // Truncated 表示 Body 超过了 CaptureMaxBodyBytes，只记录了一部分。
+}
+
+// CaptureResponse 是录制的应答。
+type CaptureResponse struct {
+	Status    int             `json:"status"`              // Status 是 HTTP 状态码。
+	Header    http.Header     `json:"header"`              // Header 是应答 header，敏感 header 会被删除。
+	Envelope  json.RawMessage `json:"envelope,omitempty"`  // Envelope 是 JSON 格式的应答，例如 `{"err":0,"msg":"","now":"","data":{}}`。
+	Body      string          `json:"body,omitempty"`      // Body 是非 JSON 格式的应答。
+	Truncated bool            `json:"truncated,omitempty"` // Truncated 表示应答超过了 CaptureMaxBodyBytes，只记录了一部分。
+}
+
+// capturer 按照采样率将请求和应答写入录制文件。
+//
+// 记录由后台 goroutine 写入文件，避免磁盘 IO 拖慢请求。写入速度跟不上时新的记录会被丢弃。
+type capturer struct {
+	rate         float64
+	maxBodyBytes int
+	skipped      []string
+
+	mu      sync.RWMutex
+	closed  bool
+	records chan *CaptureRecord
+	done    chan struct{}
+
+	file *os.File
+	enc  *json.Encoder
+}
+
+func newCapturer(config *Config) (*capturer, error) {
+	file, err := os.OpenFile(config.CaptureFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rate := config.CaptureSampleRate
+
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+
+	maxBodyBytes := config.CaptureMaxBodyBytes
+
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultCaptureMaxBodyBytes
+	}
+
+	// 管理接口和接口文档不是业务流量，而且可能包含敏感信息，不需要录制。
+	var skipped []string
+
+	for _, uri := range []string{config.AdminURI, config.OpenAPIURI} {
+		if uri == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(uri, "/") {
+			uri = "/" + uri
+		}
+
+		skipped = append(skipped, strings.TrimSuffix(uri, "/"))
+	}
+
+	cp := &capturer{
+		rate:         rate,
+		maxBodyBytes: maxBodyBytes,
+		skipped:      skipped,
+		records:      make(chan *CaptureRecord, captureQueueSize),
+		done:         make(chan struct{}),
+		file:         file,
+		enc:          json.NewEncoder(file),
+	}
+	go cp.run()
+	return cp, nil
+}
+
+// capture 是录制流量的中间件。
+func (cp *capturer) capture(c *httpContext) {
+	if cp.skip(c.Request.URL.Path) {
+		return
+	}
+
+	if cp.rate < 1 && rand.Float64() >= cp.rate {
+		return
+	}
+
+	start := time.Now()
+	req := &CaptureRequest{
+		Method: c.Request.Method,
+		URL:    captureURL(c.Request.URL),
+		Header: captureHeader(c.Request.Header),
+	}
+
+	// body 在业务代码读取时才记录，不能提前读取，否则会绕过路由的 body 大小限制，也会破坏流式上传。
+	var body *capturedBody
+
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body = &capturedBody{
+			ReadCloser:    c.Request.Body,
+			captureBuffer: captureBuffer{max: cp.maxBodyBytes},
+		}
+		c.Request.Body = body
+	}
+
+	w := &captureWriter{
+		responseWriter: c.Writer,
+		captureBuffer:  captureBuffer{max: cp.maxBodyBytes},
+	}
+	c.Writer = w
+	c.Next()
+
+	req.Route = c.FullPath()
+
+	if body != nil {
+		req.Body = body.buf.String()
+		req.Truncated = body.truncated
+	}
+
+	res := &CaptureResponse{
+		Status:    w.Status(),
+		Header:    captureHeader(w.Header()),
+		Truncated: w.truncated,
+	}
+
+	if body := w.buf.Bytes(); !w.truncated && json.Valid(body) {
+		res.Envelope = json.RawMessage(bytes.TrimSpace(body))
+	} else {
+		res.Body = string(body)
+	}
+
+	cp.write(&CaptureRecord{
+		Time:     start,
+		Request:  req,
+		Response: res,
+	})
+}
+
+// skip 判断 path 是否是管理接口或者接口文档。
+func (cp *capturer) skip(path string) bool {
+	for _, uri := range cp.skipped {
+		if path == uri || strings.HasPrefix(path, uri+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// write 将 record 放入写入队列，队列已满或者 capturer 已经关闭时丢弃这条记录。
+func (cp *capturer) write(record *CaptureRecord) {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	if cp.closed {
+		return
+	}
+
+	select {
+	case cp.records <- record:
+	default:
+		log.Warnf(context.Background(), "file=%v||go-http: capture queue is full and record is dropped", cp.file.Name())
+	}
+}
+
+// run 在后台将队列里的记录写入录制文件，直到队列被关闭。
+func (cp *capturer) run() {
+	defer close(cp.done)
+
+	for record := range cp.records {
+		if err := cp.enc.Encode(record); err != nil {
+			log.Warnf(context.Background(), "err=%v||file=%v||go-http: fail to write capture record", err, cp.file.Name())
+		}
+	}
+}
+
+// close 等待队列里的记录全部写入文件，然后关闭录制文件。
+func (cp *capturer) close() error {
+	cp.mu.Lock()
+
+	if cp.closed {
+		cp.mu.Unlock()
+		return nil
+	}
+
+	cp.closed = true
+	close(cp.records)
+	cp.mu.Unlock()
+
+	<-cp.done
+	return cp.file.Close()
+}
+
+// ReadCaptureRecords 读取录制文件中的所有记录。
+func ReadCaptureRecords(r io.Reader) ([]*CaptureRecord, error) {
+	var records []*CaptureRecord
+	dec := json.NewDecoder(r)
+
+	for {
+		record := &CaptureRecord{}
+
+		if err := dec.Decode(record); err != nil {
+			if err == io.EOF {
+				return records, nil
+			}
+
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+}
+
+// captureURL 返回请求的 path 和 query，敏感参数的值会被隐藏。
+func captureURL(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+
+	for _, k := range capturedSecretParams {
+		if _, ok := query[k]; ok {
+			query.Set(k, maskedSecret)
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return u.RequestURI()
+	}
+
+	cu := *u
+	cu.RawQuery = query.Encode()
+	return cu.RequestURI()
+}
+
+func captureHeader(header http.Header) http.Header {
+	h := make(http.Header, len(header))
+
+	for k, v := range header {
+		h[k] = append([]string(nil), v...)
+	}
+
+	for _, k := range capturedSecretHeaders {
+		h.Del(k)
+	}
+
+	return h
+}
+
+// captureBuffer 记录不超过 max 字节的数据。
+type captureBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (cb *captureBuffer) record(data []byte) {
+	if cb.truncated {
+		return
+	}
+
+	if left := cb.max - cb.buf.Len(); len(data) > left {
+		data = data[:left]
+		cb.truncated = true
+	}
+
+	cb.buf.Write(data)
+}
+
+// capturedBody 在业务代码读取请求 body 的同时记录读到的内容，业务代码没有读取的部分不会被记录。
+type capturedBody struct {
+	io.ReadCloser
+	captureBuffer
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.record(p[:n])
+	return n, err
+}
+
+// captureWriter 在写应答的同时记录应答内容。
+type captureWriter struct {
+	responseWriter
+	captureBuffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.responseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.responseWriter.WriteString(s)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testCaptureRequest struct {
+	ID   int64  `form:"id" json:"-"`
+	Name string `json:"name"`
+}
+
+func testCapture(ctx context.Context, req *testCaptureRequest) (*testCommonResponse, error) {
+	return &testCommonResponse{Foo: req.Name, Bar: int(req.ID)}, nil
+}
+
+func TestCapture(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-http-test")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "capture.jsonl")
+	s := New(&Config{
+		CaptureFile:         file,
+		CaptureMaxBodyBytes: 128,
+		AdminURI:            "/debug/admin",
+		AdminToken:          "admin-token",
+		OpenAPIURI:          "openapi.json",
+	})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/capture": RouteList{
+			R("/:name", POST, testCapture),
+		},
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/capture/foo?id=12", strings.NewReader(`{"name":"bar"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusOK)
+
+	// 请求 body 超过 CaptureMaxBodyBytes 时只记录一部分，但是业务代码依然能读到完整的请求。
+	long := strings.Repeat("x", 256)
+	r = httptest.NewRequest(http.MethodPost, "/capture/foo", strings.NewReader(`{"name":"`+long+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusOK)
+	a.Assert(strings.Contains(w.Body.String(), long))
+
+	// 敏感的 query 参数会被隐藏。
+	r = httptest.NewRequest(http.MethodPost, "/capture/foo?id=34&token=secret", strings.NewReader(`{"name":"baz"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusOK)
+
+	// 管理接口和接口文档不会被录制。
+	for _, uri := range []string{"/debug/admin/config", "/openapi.json"} {
+		r = httptest.NewRequest(http.MethodGet, uri, nil)
+		r.Header.Set("Authorization", "Bearer admin-token")
+		w = httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		a.Equal(w.Code, http.StatusOK)
+	}
+
+	a.NilError(s.Shutdown(context.Background()))
+
+	f, err := os.Open(file)
+	a.NilError(err)
+	defer f.Close()
+	records, err := ReadCaptureRecords(f)
+	a.NilError(err)
+	a.Equal(len(records), 3)
+
+	req := records[0].Request
+	a.Equal(req.Method, http.MethodPost)
+	a.Equal(req.Route, "/capture/:name")
+	a.Equal(req.URL, "/capture/foo?id=12")
+	a.Equal(req.Body, `{"name":"bar"}`)
+	a.Equal(req.Header.Get("Authorization"), "")
+	a.Equal(req.Header.Get("Content-Type"), "application/json")
+
+	res := records[0].Response
+	a.Equal(res.Status, http.StatusOK)
+	a.Assert(strings.Contains(string(res.Envelope), `"data":{"foo":"bar","bar":12}`))
+	a.Equal(res.Body, "")
+
+	a.Assert(records[1].Request.Truncated)
+	a.Equal(len(records[1].Request.Body), 128)
+	a.Assert(records[1].Response.Truncated)
+
+	a.Equal(records[2].Request.URL, "/capture/foo?id=34&token="+url.QueryEscape(maskedSecret))
+}
+
+func TestCaptureFileError(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-http-test")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	s := New(&Config{
+		CaptureFile: filepath.Join(dir, "not-exist", "capture.jsonl"),
+	})
+	a.Equal(s.capturer, (*capturer)(nil))
+	a.NonNilError(s.Serve())
+}
@@ -8,11 +8,15 @@ import (
 const (
 	// DefaultMaxHeaderBytes 是默认的 HTTP header 大小。
 	DefaultMaxHeaderBytes = http.DefaultMaxHeaderBytes
+
+	// DefaultShutdownTimeout 是默认的 graceful shutdown 超时时间。
+	DefaultShutdownTimeout = 5 * time.Second
 )
 
 // Config 是 HTTP server 的配置。
 type Config struct {
-	Addr string `config:"addr"` // 服务器监听的地址。
+	Addr   string   `config:"addr"`   // 服务器监听的地址。
+	Listen []string `config:"listen"` // Listen 设置更多的监听地址，支持 TCP、Unix socket 和继承的 fd，格式详见 README。
 
 	ReadTimeout       time.Duration `config:"read_timeout"`        // ReadTimeout 设置读 HTTP 数据超时。
 	ReadHeaderTimeout time.Duration `config:"read_header_timeout"` // ReadHeaderTimeout 设置读 HTTP header 超时。
@@ -20,7 +24,42 @@ type Config struct {
 	IdleTimeout       time.Duration `config:"idle_timeout"`        // IdleTimeout 设置空闲超时。
 	MaxHeaderBytes    int           `config:"max_header_bytes"`    // MaxHeaderBytes 设置 HTTP header 最大大小，默认是 DefaultMaxHeaderBytes。
 
-	Debug bool `config:"debug"` // Debug 表示是否处于调试状态。
+	MaxBodyBytes        int64         `config:"max_body_bytes"`         // MaxBodyBytes 设置请求 body 的最大大小，默认为 0 表示不限制，单条路由可以通过 Route#MaxBodyBytes 修改。
+	MinBodyReadRate     int64         `config:"min_body_read_rate"`     // MinBodyReadRate 设置读取请求 body 的最低速率，单位是字节每秒，为 0 表示不限制。
+	BodyReadGracePeriod time.Duration `config:"body_read_grace_period"` // BodyReadGracePeriod 设置开始读取 body 之后不检查读取速率的时间，默认是 DefaultBodyReadGracePeriod。
+
+	TLSCertFile string `config:"tls_cert_file"` // TLSCertFile 设置 TLS 证书文件，设置了证书和私钥之后服务会使用 HTTPS。
+	TLSKeyFile  string `config:"tls_key_file"`  // TLSKeyFile 设置 TLS 私钥文件。
+
+	H2C                       bool   `config:"h2c"`                          // H2C 表示是否在非 TLS 连接上支持 HTTP/2（h2c）。
+	HTTP2MaxConcurrentStreams uint32 `config:"http2_max_concurrent_streams"` // HTTP2MaxConcurrentStreams 设置 HTTP/2 单个连接的最大并发 stream 数，默认是 250。
+	HTTP2MaxReadFrameSize     uint32 `config:"http2_max_read_frame_size"`    // HTTP2MaxReadFrameSize 设置 HTTP/2 最大可读取的 frame 大小，默认是 1MB。
+
+	ShutdownTimeout time.Duration `config:"shutdown_timeout"` // ShutdownTimeout 设置 graceful shutdown 的最长等待时间，默认是 DefaultShutdownTimeout。
+
+	Upgrade        bool          `config:"upgrade"`         // Upgrade 表示是否允许通过 SIGUSR2 信号进行热升级。
+	UpgradeTimeout time.Duration `config:"upgrade_timeout"` // UpgradeTimeout 设置热升级时等待新进程就绪的超时时间，默认是 DefaultUpgradeTimeout。
+
+	TrustedProxies []string `config:"trusted_proxies"` // TrustedProxies 设置可信代理的 IP 或 CIDR，"unix" 表示信任 Unix socket 连接，ClientIP 只会信任这些代理传入的 X-Forwarded-For。
+
+	Debug   bool `config:"debug"`   // Debug 表示是否处于调试状态，调试状态下 panic 的详细信息会输出在应答里。
+	Repanic bool `config:"repanic"` // Repanic 表示处理完 panic 之后是否重新 panic，一般只在测试中使用。
+
+	Engine string `config:"engine"` // Engine 是底层的路由引擎，可以是 EngineGin 或 EngineStd，默认是 EngineGin，使用 nogin 构建标签时默认是 EngineStd。
 
 	PingURI string `config:"ping_uri"` // PingURI 表示用作探针的 uri 地址，这个接口会在服务正常的时候返回 HTTP 200 OK。
+
+	OpenAPIURI string `config:"openapi_uri"` // OpenAPIURI 设置 OpenAPI 3 接口文档的 uri 地址，为空表示不提供接口文档。
+
+	AdminURI   string `config:"admin_uri"`                 // AdminURI 设置管理接口的 uri 前缀，为空表示不开启管理接口。
+	AdminAddr  string `config:"admin_addr"`                // AdminAddr 设置管理接口单独监听的地址，为空表示与业务接口共用地址。
+	AdminToken string `config:"admin_token" secret:"true"` // AdminToken 设置访问管理接口需要的 token，为空表示不校验。
+
+	SSEHeartbeatInterval time.Duration `config:"sse_heartbeat_interval"` // SSEHeartbeatInterval 设置 Server-Sent Events 的心跳间隔，默认是 DefaultSSEHeartbeatInterval。
+
+	WSPingInterval time.Duration `config:"ws_ping_interval"` // WSPingInterval 设置 WebSocket 的 ping 间隔，客户端两个间隔内没有回复 pong 会断开连接，默认是 DefaultWSPingInterval。
+
+	CaptureFile         string  `config:"capture_file"`           // CaptureFile 设置录制流量的文件，为空表示不录制，文件格式详见 CaptureRecord。
+	CaptureSampleRate   float64 `config:"capture_sample_rate"`    // CaptureSampleRate 设置录制流量的采样率，取值范围是 (0, 1]，默认全部录制。
+	CaptureMaxBodyBytes int     `config:"capture_max_body_bytes"` // CaptureMaxBodyBytes 设置录制时记录的最大 body 大小，默认是 DefaultCaptureMaxBodyBytes。
 }
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ContextDecorator 用来修改框架为每个请求创建的 ctx，
+// 一般用来向 ctx 注入用户身份等信息，r 是当前请求。
+type ContextDecorator func(ctx context.Context, r *http.Request) context.Context
+
+// Clock 返回当前时间。
+type Clock func() time.Time
+
+type keyClockType struct{}
+
+var keyClock keyClockType
+
+// DecorateContext 注册一个 ContextDecorator，框架创建请求 ctx 之后会按照注册顺序调用所有 ContextDecorator。
+// 这个函数应该在 Serve 之前调用，一般放在 OnStart 回调里。
+func (s *Server) DecorateContext(decorator ContextDecorator) {
+	if decorator == nil {
+		return
+	}
+
+	s.decorators = append(s.decorators, decorator)
+}
+
+// prepareContext 是所有请求最先执行的中间件，记录处理请求时需要的信息。
+func (s *Server) prepareContext(c *httpContext) {
+	c.server = s
+	c.requestContext = c.Request.Context()
+	c.body, _ = c.Request.Body.(*limitedBody)
+}
+
+// serverFrom 返回处理当前请求的 Server，如果请求不是通过 Server 处理的则返回 nil。
+func serverFrom(c *httpContext) *Server {
+	return c.server
+}
+
+// requestContextFrom 返回原始请求的 ctx。
+// 框架会为业务代码创建新的 ctx，需要通过原始请求 ctx 才能知道客户端是否已经断开。
+func requestContextFrom(c *httpContext) context.Context {
+	if c.requestContext != nil {
+		return c.requestContext
+	}
+
+	return c.Request.Context()
+}
+
+func decorateContext(ctx context.Context, c *httpContext) context.Context {
+	s := serverFrom(c)
+
+	if s == nil {
+		return ctx
+	}
+
+	for _, decorator := range s.decorators {
+		ctx = decorator(ctx, c.Request)
+	}
+
+	return ctx
+}
+
+// WithClock 返回一个使用 clock 作为时钟的 ctx，
+// 框架会使用这个时钟生成应答里的 now 字段，业务代码可以通过 Now 得到同一个时钟的时间。
+// 一般只在测试中配合 ContextDecorator 使用。
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	if clock == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, keyClock, clock)
+}
+
+// Now 返回 ctx 中时钟的当前时间，如果没有通过 WithClock 设置时钟则返回 time.Now()。
+func Now(ctx context.Context) time.Time {
+	if clock, ok := ctx.Value(keyClock).(Clock); ok {
+		return clock()
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,397 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// EngineGin 表示使用 gin 作为路由引擎，这是默认值。使用 nogin 构建标签时不链接 gin，这个引擎不可用。
+	EngineGin = "gin"
+
+	// EngineStd 表示使用标准库 http.ServeMux 作为路由引擎，需要 Go 1.22 以上版本。使用 nogin 构建标签时这是默认值。
+	EngineStd = "std"
+)
+
+// mimeJSON 是 JSON 的 Content-Type。
+const mimeJSON = "application/json"
+
+// handlerFunc 是框架内部的处理函数，与具体的路由引擎无关。
+type handlerFunc func(c *httpContext)
+
+// engine 是底层的路由引擎，Server 和 Router 通过它注册路由和处理请求。
+type engine interface {
+	http.Handler
+
+	// Use 添加对之后注册的所有路由生效的中间件。
+	Use(handlers ...handlerFunc)
+
+	// Handle 注册一条路由，method 为空表示接受任意方法，path 使用 gin 风格的路径参数，例如 /user/:id 和 /static/*path。
+	// GET 路由同时处理 HEAD 请求，但不输出 body，除非同一个路径注册了 HEAD 路由。
+	Handle(method, path string, handlers ...handlerFunc) error
+
+	// NoRoute 设置没有匹配到任何路由时的处理函数，Use 添加的中间件同样生效。
+	NoRoute(handlers ...handlerFunc)
+
+	// Methods 返回所有能匹配 path 的路由的请求方法，按照注册顺序排列。
+	Methods(path string) []string
+}
+
+// newEngine 根据名字创建路由引擎，name 为空时使用 defaultEngine。
+func newEngine(name string, debug bool) (engine, error) {
+	if name == "" {
+		name = defaultEngine
+	}
+
+	switch name {
+	case EngineGin:
+		return newGinEngine(debug)
+	case EngineStd:
+		return newStdEngine(), nil
+	}
+
+	return nil, fmt.Errorf("go-http: unknown engine [engine:%v]", name)
+}
+
+// anyMethods 是 method 为空的路由接受的请求方法，与 gin 的 Any 一致。
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodHead,
+	http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace,
+}
+
+// methodTable 记录路由引擎中所有路由的请求方法，用于在没有匹配到路由时计算 Allow header。
+//
+// 不含参数的路径直接按照路径索引，只有含参数的路径需要逐个匹配。
+type methodTable struct {
+	mu       sync.RWMutex
+	static   map[string][]methodRoute
+	patterns []methodRoute
+	seq      int
+}
+
+type methodRoute struct {
+	seq    int
+	method string
+	path   string
+}
+
+func (mt *methodTable) add(method, path string) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.seq++
+	r := methodRoute{seq: mt.seq, method: method, path: path}
+
+	if !strings.ContainsAny(path, ":*") {
+		if mt.static == nil {
+			mt.static = map[string][]methodRoute{}
+		}
+
+		mt.static[path] = append(mt.static[path], r)
+		return
+	}
+
+	mt.patterns = append(mt.patterns, r)
+}
+
+func (mt *methodTable) match(path string) []string {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	static := mt.static[path]
+	matched := make([]methodRoute, 0, len(static))
+	matched = append(matched, static...)
+
+	for _, r := range mt.patterns {
+		if matchRoutePath(r.path, path) {
+			matched = append(matched, r)
+		}
+	}
+
+	// 按照注册顺序排列。
+	if len(static) != 0 && len(matched) > len(static) {
+		sort.Slice(matched, func(i, j int) bool {
+			return matched[i].seq < matched[j].seq
+		})
+	}
+
+	var methods []string
+	seen := map[string]bool{}
+
+	for _, r := range matched {
+		candidates := []string{r.method}
+
+		if r.method == "" {
+			candidates = anyMethods
+		}
+
+		for _, m := range candidates {
+			if !seen[m] {
+				seen[m] = true
+				methods = append(methods, m)
+			}
+		}
+	}
+
+	return methods
+}
+
+// routeParam 是路由中的一个路径参数。
+type routeParam struct {
+	key   string
+	value string
+}
+
+type routeParams []routeParam
+
+func (ps routeParams) byName(name string) string {
+	for _, p := range ps {
+		if p.key == name {
+			return p.value
+		}
+	}
+
+	return ""
+}
+
+// httpContext 是处理一个请求时的上下文，由路由引擎创建。
+type httpContext struct {
+	Request *http.Request
+	Writer  responseWriter
+
+	route  string
+	params routeParams
+
+	server         *Server
+	requestContext context.Context
+
+	body *limitedBody
+
+	handlers []handlerFunc
+	index    int
+	aborted  bool
+	panicked bool
+}
+
+func newHTTPContext(w http.ResponseWriter, r *http.Request, route string, params routeParams, handlers []handlerFunc) *httpContext {
+	return &httpContext{
+		Request:  r,
+		Writer:   newResponseWriter(w),
+		route:    route,
+		params:   params,
+		handlers: handlers,
+		index:    -1,
+	}
+}
+
+// Next 依次执行剩下的处理函数，直到所有函数执行完毕或者调用了 Abort。
+func (c *httpContext) Next() {
+	for c.index++; c.index < len(c.handlers) && !c.aborted; c.index++ {
+		c.handlers[c.index](c)
+	}
+}
+
+// Abort 中止执行剩下的处理函数。
+func (c *httpContext) Abort() {
+	c.aborted = true
+}
+
+// FullPath 返回当前请求匹配到的路由。
+func (c *httpContext) FullPath() string {
+	return c.route
+}
+
+// GetHeader 返回请求中的 header。
+func (c *httpContext) GetHeader(key string) string {
+	return c.Request.Header.Get(key)
+}
+
+// ContentType 返回请求的 Content-Type，不包含 charset 等参数。
+func (c *httpContext) ContentType() string {
+	ct := c.GetHeader("Content-Type")
+
+	if idx := strings.IndexAny(ct, " ;"); idx >= 0 {
+		ct = ct[:idx]
+	}
+
+	return ct
+}
+
+// BindQuery 将 query 解析到 ptr 里，规则与 gin 的 binding 包一致。
+func (c *httpContext) BindQuery(ptr interface{}) error {
+	if err := bindQuery(c.Request.URL.Query(), ptr); err != nil {
+		c.Abort()
+		return err
+	}
+
+	return nil
+}
+
+// BindJSON 将 JSON 格式的 body 解析到 ptr 里，规则与 gin 的 binding 包一致。
+func (c *httpContext) BindJSON(ptr interface{}) error {
+	var body io.Reader
+
+	if c.Request.Body != nil {
+		body = c.Request.Body
+	}
+
+	if err := bindJSON(body, ptr); err != nil {
+		c.Abort()
+		return err
+	}
+
+	return nil
+}
+
+// Status 设置应答的状态码，状态码会在第一次写入数据时输出。
+func (c *httpContext) Status(status int) {
+	c.Writer.WriteHeader(status)
+}
+
+// Data 输出应答。
+func (c *httpContext) Data(status int, contentType string, data []byte) {
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Status(status)
+	c.Writer.Write(data)
+}
+
+// responseWriter 是框架使用的 http.ResponseWriter，记录了应答的状态码和长度。
+type responseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+
+	// Status 返回应答的状态码。
+	Status() int
+
+	// Size 返回已经输出的 body 长度，如果还没有输出 header 则返回 -1。
+	Size() int
+
+	// Written 判断是否已经输出了 header。
+	Written() bool
+
+	// WriteHeaderNow 立即输出 header。
+	WriteHeaderNow()
+
+	// WriteString 输出字符串。
+	WriteString(s string) (int, error)
+
+	// Unwrap 返回原始的 http.ResponseWriter，供 http.ResponseController 使用。
+	Unwrap() http.ResponseWriter
+}
+
+type basicResponseWriter struct {
+	http.ResponseWriter
+
+	// raw 是 net/http 传入的原始 http.ResponseWriter，ResponseWriter 被 gin 包装过时才需要设置。
+	raw http.ResponseWriter
+
+	status int
+	size   int
+}
+
+func newResponseWriter(w http.ResponseWriter) *basicResponseWriter {
+	return &basicResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		size:           -1,
+	}
+}
+
+func (w *basicResponseWriter) WriteHeader(status int) {
+	if status > 0 && !w.Written() {
+		w.status = status
+	}
+}
+
+func (w *basicResponseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *basicResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *basicResponseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write([]byte(s))
+	w.size += n
+	return n, err
+}
+
+func (w *basicResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *basicResponseWriter) Size() int {
+	return w.size
+}
+
+func (w *basicResponseWriter) Written() bool {
+	return w.size != -1
+}
+
+func (w *basicResponseWriter) Flush() {
+	w.WriteHeaderNow()
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *basicResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, errors.New("go-http: response writer does not support hijacking")
+	}
+
+	if w.size < 0 {
+		w.size = 0
+	}
+
+	return h.Hijack()
+}
+
+// Unwrap 返回原始的 http.ResponseWriter，供 http.ResponseController 使用。
+func (w *basicResponseWriter) Unwrap() http.ResponseWriter {
+	if w.raw != nil {
+		return w.raw
+	}
+
+	return w.ResponseWriter
+}
+
+// discardBody 丢弃应答的 body，用于按照 GET 路由处理 HEAD 请求，需要放在所有处理函数的最前面。
+func discardBody(c *httpContext) {
+	c.Writer = &headResponseWriter{responseWriter: c.Writer}
+}
+
+// headResponseWriter 丢弃所有 body，用于将 HEAD 请求按照 GET 请求处理。
+type headResponseWriter struct {
+	responseWriter
+}
+
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return len(data), nil
+}
+
+func (w *headResponseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return len(s), nil
+}
@@ -0,0 +1,150 @@
+//go:build !nogin
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginEngine 是基于 gin 的路由引擎。
+type ginEngine struct {
+	engine      *gin.Engine
+	middlewares []handlerFunc
+	methods     methodTable
+	heads       map[string]*ginHead
+}
+
+// ginHead 是一个路径上的 HEAD 路由，GET 路由附带的 HEAD 路由可以被之后注册的 HEAD 路由替换。
+type ginHead struct {
+	handler  gin.HandlerFunc
+	implicit bool
+}
+
+// defaultEngine 是 Config 中没有设置 Engine 时使用的路由引擎。
+const defaultEngine = EngineGin
+
+func newGinEngine(debug bool) (engine, error) {
+	if !debug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	return &ginEngine{
+		engine: gin.New(),
+		heads:  map[string]*ginHead{},
+	}, nil
+}
+
+func (ge *ginEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// gin 的 ResponseWriter 不支持 Unwrap，需要记下原始的 http.ResponseWriter，
+	// 否则 http.ResponseController 无法设置读写超时。
+	ge.engine.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyRawResponseWriter, w)))
+}
+
+type keyRawResponseWriterType struct{}
+
+var keyRawResponseWriter keyRawResponseWriterType
+
+func (ge *ginEngine) Use(handlers ...handlerFunc) {
+	ge.middlewares = append(ge.middlewares, handlers...)
+}
+
+func (ge *ginEngine) Handle(method, path string, handlers ...handlerFunc) (err error) {
+	hf := ge.wrap(handlers)
+
+	// gin 遇到冲突的路由会 panic，转换成错误返回。
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("go-http: fail to register route [method:%v] [path:%v] [err:%v]", method, path, r)
+		}
+	}()
+
+	switch method {
+	case "":
+		ge.engine.Any(path, hf)
+	case http.MethodGet:
+		ge.engine.GET(path, hf)
+
+		if _, ok := ge.heads[path]; !ok {
+			ge.handleHead(path, ge.wrapChain(ge.headChain(handlers)), true)
+		}
+	case http.MethodHead:
+		if head, ok := ge.heads[path]; ok && head.implicit {
+			head.handler = hf
+			head.implicit = false
+		} else {
+			ge.handleHead(path, hf, false)
+		}
+	default:
+		ge.engine.Handle(method, path, hf)
+	}
+
+	ge.methods.add(method, path)
+	return nil
+}
+
+func (ge *ginEngine) NoRoute(handlers ...handlerFunc) {
+	ge.engine.NoRoute(ge.wrap(handlers))
+}
+
+func (ge *ginEngine) Methods(path string) []string {
+	return ge.methods.match(path)
+}
+
+// handleHead 注册 path 的 HEAD 路由，implicit 表示这是 GET 路由附带的 HEAD 路由。
+func (ge *ginEngine) handleHead(path string, hf gin.HandlerFunc, implicit bool) {
+	head := &ginHead{
+		handler:  hf,
+		implicit: implicit,
+	}
+	ge.engine.HEAD(path, func(c *gin.Context) {
+		head.handler(c)
+	})
+	ge.heads[path] = head
+}
+
+// headChain 返回按照 GET 路由处理 HEAD 请求的处理函数，最先丢弃 body，中间件也不会看到 body。
+func (ge *ginEngine) headChain(handlers []handlerFunc) []handlerFunc {
+	chain := make([]handlerFunc, 0, len(ge.middlewares)+len(handlers)+1)
+	chain = append(chain, discardBody)
+	chain = append(chain, ge.middlewares...)
+	chain = append(chain, handlers...)
+	return chain
+}
+
+// wrap 将中间件和 handlers 组合成 gin 的处理函数。
+func (ge *ginEngine) wrap(handlers []handlerFunc) gin.HandlerFunc {
+	chain := make([]handlerFunc, 0, len(ge.middlewares)+len(handlers))
+	chain = append(chain, ge.middlewares...)
+	chain = append(chain, handlers...)
+	return ge.wrapChain(chain)
+}
+
+// wrapChain 将完整的处理函数列表转换成 gin 的处理函数。
+func (ge *ginEngine) wrapChain(chain []handlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var params routeParams
+
+		if len(c.Params) != 0 {
+			params = make(routeParams, 0, len(c.Params))
+
+			for _, p := range c.Params {
+				params = append(params, routeParam{key: p.Key, value: p.Value})
+			}
+		}
+
+		hc := newHTTPContext(c.Writer, c.Request, c.FullPath(), params, chain)
+
+		if raw, ok := c.Request.Context().Value(keyRawResponseWriter).(http.ResponseWriter); ok {
+			hc.Writer.(*basicResponseWriter).raw = raw
+		}
+
+		hc.Next()
+
+		// 处理函数可能只设置了状态码而没有输出 body，需要确保状态码被输出。
+		hc.Writer.WriteHeaderNow()
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !nogin
+
+package server
+
+import "testing"
+
+// testEngines 是需要逐个测试的路由引擎。
+var testEngines = []string{EngineGin, EngineStd}
+
+func BenchmarkEngineGin(b *testing.B) {
+	benchmarkEngine(b, EngineGin, "/pointer?uid=12")
+}
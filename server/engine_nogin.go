@@ -0,0 +1,12 @@
+//go:build nogin
+
+package server
+
+import "fmt"
+
+// defaultEngine 是 Config 中没有设置 Engine 时使用的路由引擎，使用 nogin 构建标签时不链接 gin。
+const defaultEngine = EngineStd
+
+func newGinEngine(debug bool) (engine, error) {
+	return nil, fmt.Errorf("go-http: engine is not available when built with the nogin tag [engine:%v]", EngineGin)
+}
@@ -0,0 +1,30 @@
+//go:build nogin
+
+package server
+
+import (
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+// testEngines 是需要逐个测试的路由引擎。
+var testEngines = []string{EngineStd}
+
+func TestNoGinEngine(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := newEngine(EngineGin, false)
+	a.NonNilError(err)
+
+	// 默认使用 std 引擎，依然可以正常注册路由。
+	s := New(&Config{})
+	a.NilError(s.err)
+	_, ok := s.engine.(*stdEngine)
+	a.Assert(ok)
+
+	s = New(&Config{
+		Engine: EngineGin,
+	})
+	a.NonNilError(s.Serve())
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// stdEngine 是基于标准库 http.ServeMux 的路由引擎，使用 Go 1.22 引入的路由模式。
+//
+// 注册路由时，gin 风格的路径参数会转换成 ServeMux 的格式：
+//     - /user/:id 转换成 /user/{id}；
+//     - /static/*path 转换成 /static/{path...}，参数值与 gin 一样以“/”开头；
+//     - 以“/”结尾的路径只匹配这个路径本身，不会匹配子路径。
+type stdEngine struct {
+	mux         *http.ServeMux
+	middlewares []handlerFunc
+	methods     methodTable
+	noRoute     http.HandlerFunc
+}
+
+func newStdEngine() *stdEngine {
+	return &stdEngine{
+		mux: http.NewServeMux(),
+	}
+}
+
+func (se *stdEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// ServeMux 没有匹配到路由时返回空的 pattern，此时交给 NoRoute 设置的处理函数。
+	if se.noRoute != nil {
+		if _, pattern := se.mux.Handler(r); pattern == "" {
+			se.noRoute(w, r)
+			return
+		}
+	}
+
+	se.mux.ServeHTTP(w, r)
+}
+
+func (se *stdEngine) Use(handlers ...handlerFunc) {
+	se.middlewares = append(se.middlewares, handlers...)
+}
+
+func (se *stdEngine) Handle(method, path string, handlers ...handlerFunc) (err error) {
+	chain := se.chain(handlers)
+	pattern, names, catchAll := stdPattern(path)
+
+	// ServeMux 的 GET 路由同时匹配 HEAD 请求，这时需要丢弃 body，中间件也不会看到 body。
+	var headChain []handlerFunc
+
+	if method == http.MethodGet {
+		headChain = append([]handlerFunc{discardBody}, chain...)
+	}
+
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+
+	// ServeMux 遇到冲突的路由会 panic，转换成错误返回。
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("go-http: fail to register route [method:%v] [path:%v] [err:%v]", method, path, r)
+		}
+	}()
+
+	se.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		var params routeParams
+
+		if len(names) != 0 {
+			params = make(routeParams, 0, len(names))
+
+			for _, name := range names {
+				value := r.PathValue(name)
+
+				if name == catchAll {
+					value = "/" + value
+				}
+
+				params = append(params, routeParam{key: name, value: value})
+			}
+		}
+
+		hfs := chain
+
+		if headChain != nil && r.Method == http.MethodHead {
+			hfs = headChain
+		}
+
+		c := newHTTPContext(w, r, path, params, hfs)
+		c.Next()
+
+		// 处理函数可能只设置了状态码而没有输出 body，需要确保状态码被输出。
+		c.Writer.WriteHeaderNow()
+	})
+	se.methods.add(method, path)
+	return nil
+}
+
+func (se *stdEngine) NoRoute(handlers ...handlerFunc) {
+	chain := se.chain(handlers)
+	se.noRoute = func(w http.ResponseWriter, r *http.Request) {
+		c := newHTTPContext(w, r, "", nil, chain)
+		c.Writer.WriteHeader(http.StatusNotFound)
+		c.Next()
+		c.Writer.WriteHeaderNow()
+	}
+}
+
+func (se *stdEngine) Methods(path string) []string {
+	return se.methods.match(path)
+}
+
+func (se *stdEngine) chain(handlers []handlerFunc) []handlerFunc {
+	chain := make([]handlerFunc, 0, len(se.middlewares)+len(handlers))
+	chain = append(chain, se.middlewares...)
+	chain = append(chain, handlers...)
+	return chain
+}
+
+// stdPattern 将 gin 风格的路径转换成 ServeMux 的路由模式，返回模式、所有参数名和通配参数名。
+func stdPattern(path string) (pattern string, names []string, catchAll string) {
+	segments := strings.Split(path, "/")
+
+	for i, seg := range segments {
+		if len(seg) < 2 {
+			continue
+		}
+
+		switch seg[0] {
+		case ':':
+			names = append(names, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		case '*':
+			catchAll = seg[1:]
+			names = append(names, catchAll)
+			segments[i] = "{" + catchAll + "...}"
+		}
+	}
+
+	pattern = strings.Join(segments, "/")
+
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "{$}"
+	}
+
+	return
+}
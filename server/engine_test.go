@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testEngineRequest struct {
+	Name string `form:"name"`
+}
+
+type testEngineResponse struct {
+	Route  string            `json:"route"`
+	Params map[string]string `json:"params"`
+	Name   string            `json:"name"`
+}
+
+func testEngine(ctx context.Context, req *testEngineRequest) (*testEngineResponse, error) {
+	return &testEngineResponse{
+		Route:  RouteFrom(ctx),
+		Params: Params(ctx),
+		Name:   req.Name,
+	}, nil
+}
+
+func TestEngines(t *testing.T) {
+	for _, engine := range testEngines {
+		t.Run(engine, func(t *testing.T) {
+			a := assert.New(t)
+			s := New(&Config{
+				Engine:  engine,
+				PingURI: "/ping",
+			})
+			a.NilError(s.AddRoutes(RouteMap{
+				"/user": RouteList{
+					R(":id", GET, testEngine),
+					R(":id/files/*path", GET, testEngine),
+				},
+				"/users": RouteList{
+					R("list/", GET, testEngine),
+				},
+				"/any": RouteList{
+					R("", ANY, testEngine),
+				},
+				"/raw": RouteList{
+					R("", GET, func(w http.ResponseWriter, r *http.Request) {
+						w.Write([]byte("raw"))
+					}),
+				},
+			}))
+
+			call := func(method, uri string) (int, string) {
+				r := httptest.NewRequest(method, uri, nil)
+				w := httptest.NewRecorder()
+				s.Handler().ServeHTTP(w, r)
+				body, _ := ioutil.ReadAll(w.Body)
+				return w.Code, string(body)
+			}
+			data := func(body string) string {
+				return body[:strings.Index(body, `,"err"`)]
+			}
+
+			code, body := call(http.MethodGet, "/user/12?name=foo")
+			a.Equal(code, http.StatusOK)
+			a.Equal(data(body), `{"data":{"route":"/user/:id","params":{"id":"12"},"name":"foo"}`)
+
+			code, body = call(http.MethodGet, "/user/12/files/a/b.txt")
+			a.Equal(code, http.StatusOK)
+			a.Equal(data(body), `{"data":{"route":"/user/:id/files/*path","params":{"id":"12","path":"/a/b.txt"},"name":""}`)
+
+			code, body = call(http.MethodGet, "/users/list/")
+			a.Equal(code, http.StatusOK)
+			a.Equal(data(body), `{"data":{"route":"/users/list/","params":{},"name":""}`)
+
+			code, _ = call(http.MethodGet, "/users/list/more")
+			a.Equal(code, http.StatusNotFound)
+
+			for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+				code, _ = call(method, "/any")
+				a.Equal(code, http.StatusOK)
+			}
+
+			code, body = call(http.MethodGet, "/raw")
+			a.Equal(code, http.StatusOK)
+			a.Equal(body, "raw")
+
+			code, body = call(http.MethodGet, "/ping")
+			a.Equal(code, http.StatusOK)
+			a.Equal(body, "OK")
+
+			code, _ = call(http.MethodPost, "/raw")
+			a.NotEqual(code, http.StatusOK)
+
+			// 冲突的路由返回错误，而不是 panic。
+			a.NonNilError(s.AddRoutes(RouteList{
+				R("/user/:id", GET, testEngine),
+			}))
+			a.NonNilError(s.AddRoutes(RouteList{
+				R("/ping", GET, testEngine),
+			}))
+		})
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := newEngine("unknown", false)
+	a.NonNilError(err)
+
+	s := New(&Config{
+		Engine: "unknown",
+	})
+	a.NonNilError(s.Serve())
+	a.NonNilError(s.ServeListener(nil))
+}
+
+func TestStdPattern(t *testing.T) {
+	a := assert.New(t)
+	cases := []struct {
+		path     string
+		pattern  string
+		names    []string
+		catchAll string
+	}{
+		{"/", "/{$}", nil, ""},
+		{"/user/:id", "/user/{id}", []string{"id"}, ""},
+		{"/user/:id/", "/user/{id}/{$}", []string{"id"}, ""},
+		{"/static/*path", "/static/{path...}", []string{"path"}, "path"},
+	}
+
+	for _, c := range cases {
+		pattern, names, catchAll := stdPattern(c.path)
+		a.Equal(pattern, c.pattern)
+		a.Equal(names, c.names)
+		a.Equal(catchAll, c.catchAll)
+	}
+}
+
+func TestMethodTable(t *testing.T) {
+	a := assert.New(t)
+	mt := &methodTable{}
+	mt.add(http.MethodDelete, "/user/:id")
+	mt.add(http.MethodGet, "/user/list")
+	mt.add(http.MethodPost, "/user/list")
+	mt.add("", "/static/*path")
+
+	a.Equal(mt.match("/user/list"), []string{http.MethodDelete, http.MethodGet, http.MethodPost})
+	a.Equal(mt.match("/user/12"), []string{http.MethodDelete})
+	a.Equal(mt.match("/static/a.js"), anyMethods)
+	a.Equal(mt.match("/other"), []string(nil))
+}
+
+func BenchmarkEngineStd(b *testing.B) {
+	benchmarkEngine(b, EngineStd, "/pointer?uid=12")
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestWriteEnvelope(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2020, 5, 1, 12, 30, 0, 0, time.UTC)
+	ctx := context.WithValue(context.Background(), keyStartTime, now)
+	ctx = WithClock(ctx, func() time.Time { return now })
+
+	cases := []struct {
+		status int
+		em     *errorMsg
+		data   interface{}
+	}{
+		{http.StatusOK, errorMsgOK, newTestCommonResponse()},
+		{http.StatusOK, errorMsgOK, nil},
+		{http.StatusOK, newErrorMsg(1001, "<failed> & \"quoted\"", errors.New("oops")), newTestCommonResponse()},
+		{http.StatusBadRequest, newErrorMsg(ErrCodeBadRequest, "bad request"), nil},
+		{http.StatusNoContent, errorMsgOK, nil},
+	}
+
+	for _, c := range cases {
+		expected := httptest.NewRecorder()
+		ec := newHTTPContext(expected, httptest.NewRequest(http.MethodGet, "/", nil), "/", nil, nil)
+		writeResponse(ctx, ec, c.status, c.em.ToH(c.data))
+
+		actual := httptest.NewRecorder()
+		ac := newHTTPContext(actual, httptest.NewRequest(http.MethodGet, "/", nil), "/", nil, nil)
+		writeEnvelope(ctx, ac, c.status, c.em, c.data)
+
+		a.Equal(actual.Code, expected.Code)
+		a.Equal(actual.Header(), expected.Header())
+		a.Equal(actual.Body.String(), expected.Body.String())
+	}
+}
+
+func TestWriteEnvelopeInvalidData(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.WithValue(context.Background(), keyStartTime, time.Now())
+	c := newHTTPContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "/", nil, nil)
+
+	defer func() {
+		a.Assert(recover() != nil)
+	}()
+	writeEnvelope(ctx, c, http.StatusOK, errorMsgOK, func() {})
+}
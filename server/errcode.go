@@ -1,5 +1,10 @@
 package server
 
+import (
+	"sort"
+	"sync"
+)
+
 const (
 	// ErrCodeOK 代表业务正常。
 	ErrCodeOK = 0
@@ -13,4 +18,61 @@ const (
 
 	// ErrCodeServerPanic 代表业务代码崩溃，框架抓住这个错误并返回错误信息。
 	ErrCodeServerPanic = 3
+
+	// ErrCodeNotFound 代表请求的路径没有匹配到任何路由。
+	ErrCodeNotFound = 4
+
+	// ErrCodeMethodNotAllowed 代表请求的路径存在，但是不接受这个请求方法。
+	ErrCodeMethodNotAllowed = 5
+
+	// ErrCodePayloadTooLarge 代表请求 body 超过了大小限制。
+	ErrCodePayloadTooLarge = 6
+
+	// ErrCodeRequestTimeout 代表客户端发送请求 body 的速度太慢。
+	ErrCodeRequestTimeout = 7
+
+	// ErrCodeTransportError 代表调用下游服务时发生了网络错误，或者下游服务没有返回标准格式的应答。
+	// 这个错误码只会出现在调用方，不是业务错误。
+	ErrCodeTransportError = 8
 )
+
+var (
+	errCodesMu sync.RWMutex
+	errCodes   = map[int]string{
+		ErrCodeOK:               "业务正常",
+		ErrCodeBadRequest:       "上游请求参数不合法",
+		ErrCodeInvalidError:     "业务返回了一个错误的 error 类型",
+		ErrCodeServerPanic:      "业务代码崩溃",
+		ErrCodeNotFound:         "请求的路径不存在",
+		ErrCodeMethodNotAllowed: "请求的路径不接受这个请求方法",
+		ErrCodePayloadTooLarge:  "请求 body 超过了大小限制",
+		ErrCodeRequestTimeout:   "客户端发送请求 body 的速度太慢",
+		ErrCodeTransportError:   "调用下游服务时发生了网络错误",
+	}
+)
+
+// RegisterErrCode 注册一个业务错误码及其描述，注册过的错误码会出现在生成的 OpenAPI 文档里面。
+// 重复注册同一个错误码会覆盖之前的描述。
+func RegisterErrCode(code int, description string) {
+	errCodesMu.Lock()
+	defer errCodesMu.Unlock()
+	errCodes[code] = description
+}
+
+// registeredErrCodes 返回所有注册过的错误码，按照错误码从小到大排列。
+func registeredErrCodes() (codes []int, descriptions []string) {
+	errCodesMu.RLock()
+	defer errCodesMu.RUnlock()
+
+	for code := range errCodes {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		descriptions = append(descriptions, errCodes[code])
+	}
+
+	return
+}
@@ -4,14 +4,15 @@ import (
 	"fmt"
 	"strings"
 	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
 type errorMsg struct {
 	code int
 	msg  string
 	errs []error
+
+	// raw 表示 msg 是从下游服务应答中原样取出的错误信息，已经包装过，Error() 直接返回 msg。
+	raw bool
 }
 
 func newErrorMsg(code int, msg string, errs ...error) *errorMsg {
@@ -23,6 +24,10 @@ func newErrorMsg(code int, msg string, errs ...error) *errorMsg {
 }
 
 func (em *errorMsg) Error() string {
+	if em.raw {
+		return em.msg
+	}
+
 	var sb strings.Builder
 
 	fmt.Fprintf(&sb, "go-http: buniness error [code:%d] [msg:%s]", em.code, em.msg)
@@ -34,8 +39,8 @@ func (em *errorMsg) Error() string {
 	return sb.String()
 }
 
-func (em *errorMsg) ToH(data interface{}) gin.H {
-	h := gin.H{
+func (em *errorMsg) ToH(data interface{}) map[string]interface{} {
+	h := map[string]interface{}{
 		"err": em.code,
 		"now": time.Now().Format(time.RFC3339),
 	}
@@ -56,3 +61,77 @@ func (em *errorMsg) ToH(data interface{}) gin.H {
 func Error(code int, msg string, errs ...error) error {
 	return newErrorMsg(code, msg, errs...)
 }
+
+// RemoteError 构造一个从下游服务应答中解析出来的业务错误，code 和 msg 是应答中原样的错误码和错误信息。
+// 与 Error 不同，msg 不会被再次包装，这个错误经过多个服务转发时错误信息始终保持不变。
+func RemoteError(code int, msg string) error {
+	return &errorMsg{
+		code: code,
+		msg:  msg,
+		raw:  true,
+	}
+}
+
+// TransportError 将调用下游服务时发生的网络错误或者无法解析应答的错误包装起来，
+// 这种错误的错误码是 ErrCodeTransportError，但不是业务错误。
+func TransportError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &transportError{err: err}
+}
+
+type transportError struct {
+	err error
+}
+
+func (te *transportError) Error() string {
+	return te.err.Error()
+}
+
+func (te *transportError) Unwrap() error {
+	return te.err
+}
+
+// ErrorCode 返回 err 中的业务错误码。
+// 如果 err 为 nil 则返回 ErrCodeOK，如果 err 是通过 TransportError 构造的错误则返回 ErrCodeTransportError，
+// 如果 err 不是通过 Error 构造的业务错误则返回 ErrCodeInvalidError。
+func ErrorCode(err error) int {
+	if err == nil {
+		return ErrCodeOK
+	}
+
+	if _, ok := err.(*transportError); ok {
+		return ErrCodeTransportError
+	}
+
+	em, ok := err.(*errorMsg)
+
+	if !ok {
+		return ErrCodeInvalidError
+	}
+
+	return em.code
+}
+
+// IsBusinessError 判断 err 是否是通过 Error 构造的业务错误。
+func IsBusinessError(err error) bool {
+	_, ok := err.(*errorMsg)
+	return ok
+}
+
+// ErrorMessage 返回 err 中的业务错误信息，如果 err 不是通过 Error 构造的业务错误则返回 err.Error()。
+func ErrorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	em, ok := err.(*errorMsg)
+
+	if !ok {
+		return err.Error()
+	}
+
+	return em.msg
+}
@@ -0,0 +1,372 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// 请求参数的解析规则与 gin 的 binding 包一致，这样切换路由引擎或者不链接 gin 时业务代码的行为不会改变：
+//     - query 按照 form tag 解析，tag 为空时使用字段名，`form:"-"` 表示忽略这个字段；
+//     - form tag 可以通过 `default=` 设置默认值，time.Time 支持 time_format、time_utc 和 time_location tag；
+//     - 解析之后按照 binding tag 校验请求结构。
+
+var errUnknownFormType = errors.New("unknown type")
+
+var (
+	validatorOnce   sync.Once
+	structValidator *validator.Validate
+)
+
+// validateStruct 按照 binding tag 校验 ptr，只校验结构和结构指针。
+func validateStruct(ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	kind := v.Kind()
+
+	if kind == reflect.Ptr {
+		kind = v.Elem().Kind()
+	}
+
+	if kind != reflect.Struct {
+		return nil
+	}
+
+	validatorOnce.Do(func() {
+		structValidator = validator.New()
+		structValidator.SetTagName("binding")
+	})
+	return structValidator.Struct(ptr)
+}
+
+// bindQuery 将 query 解析到 ptr 里，并校验解析结果。
+func bindQuery(query map[string][]string, ptr interface{}) error {
+	if _, err := mapForm(reflect.ValueOf(ptr), reflect.StructField{}, query); err != nil {
+		return err
+	}
+
+	return validateStruct(ptr)
+}
+
+// bindJSON 将 JSON 解析到 ptr 里，并校验解析结果。
+func bindJSON(body io.Reader, ptr interface{}) error {
+	if body == nil {
+		return errors.New("invalid request")
+	}
+
+	if err := json.NewDecoder(body).Decode(ptr); err != nil {
+		return err
+	}
+
+	return validateStruct(ptr)
+}
+
+// mapForm 将 form 解析到 value 里，field 是 value 所在的结构字段，返回是否设置了任何字段。
+func mapForm(value reflect.Value, field reflect.StructField, form map[string][]string) (bool, error) {
+	if field.Tag.Get("form") == "-" {
+		return false, nil
+	}
+
+	kind := value.Kind()
+
+	if kind == reflect.Ptr {
+		ptr := value
+		isNew := value.IsNil()
+
+		if isNew {
+			ptr = reflect.New(value.Type().Elem())
+		}
+
+		set, err := mapForm(ptr.Elem(), field, form)
+
+		if err != nil {
+			return false, err
+		}
+
+		// 只有设置了字段时才分配新的结构，避免把 nil 指针变成空结构。
+		if isNew && set {
+			value.Set(ptr)
+		}
+
+		return set, nil
+	}
+
+	if kind != reflect.Struct || !field.Anonymous {
+		set, err := setFormField(value, field, form)
+
+		if err != nil {
+			return false, err
+		}
+
+		if set {
+			return true, nil
+		}
+	}
+
+	if kind != reflect.Struct {
+		return false, nil
+	}
+
+	t := value.Type()
+	set := false
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		// 忽略未导出的字段，但匿名字段里可能有导出的字段。
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		ok, err := mapForm(value.Field(i), sf, form)
+
+		if err != nil {
+			return false, err
+		}
+
+		set = set || ok
+	}
+
+	return set, nil
+}
+
+// setFormField 根据 field 的 form tag 从 form 里取值并设置 value。
+func setFormField(value reflect.Value, field reflect.StructField, form map[string][]string) (bool, error) {
+	name, opts := cutTag(field.Tag.Get("form"), ",")
+
+	if name == "" {
+		name = field.Name
+	}
+
+	// 最外层的 value 没有对应的字段。
+	if name == "" {
+		return false, nil
+	}
+
+	var def string
+	hasDefault := false
+
+	for opts != "" {
+		var opt string
+		opt, opts = cutTag(opts, ",")
+
+		if k, v := cutTag(opt, "="); k == "default" {
+			hasDefault = true
+			def = v
+		}
+	}
+
+	vs, ok := form[name]
+
+	if !ok && !hasDefault {
+		return false, nil
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		if !ok {
+			vs = []string{def}
+		}
+
+		slice := reflect.MakeSlice(value.Type(), len(vs), len(vs))
+
+		if err := setFormArray(vs, slice, field); err != nil {
+			return false, err
+		}
+
+		value.Set(slice)
+		return true, nil
+
+	case reflect.Array:
+		if !ok {
+			vs = []string{def}
+		}
+
+		if len(vs) != value.Len() {
+			return false, fmt.Errorf("%q is not valid value for %s", vs, value.Type().String())
+		}
+
+		return true, setFormArray(vs, value, field)
+	}
+
+	var val string
+
+	if !ok {
+		val = def
+	}
+
+	if len(vs) > 0 {
+		val = vs[0]
+	}
+
+	return true, setFormValue(val, value, field)
+}
+
+func setFormArray(vals []string, value reflect.Value, field reflect.StructField) error {
+	for i, s := range vals {
+		if err := setFormValue(s, value.Index(i), field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setFormValue 将字符串 val 按照 value 的类型解析并设置。
+func setFormValue(val string, value reflect.Value, field reflect.StructField) error {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, ok := value.Interface().(time.Duration); ok {
+			d, err := time.ParseDuration(val)
+
+			if err != nil {
+				return err
+			}
+
+			value.SetInt(int64(d))
+			return nil
+		}
+
+		if val == "" {
+			val = "0"
+		}
+
+		n, err := strconv.ParseInt(val, 10, value.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		value.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val == "" {
+			val = "0"
+		}
+
+		n, err := strconv.ParseUint(val, 10, value.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		value.SetUint(n)
+
+	case reflect.Bool:
+		if val == "" {
+			val = "false"
+		}
+
+		b, err := strconv.ParseBool(val)
+
+		if err != nil {
+			return err
+		}
+
+		value.SetBool(b)
+
+	case reflect.Float32, reflect.Float64:
+		if val == "" {
+			val = "0.0"
+		}
+
+		f, err := strconv.ParseFloat(val, value.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		value.SetFloat(f)
+
+	case reflect.String:
+		value.SetString(val)
+
+	case reflect.Struct:
+		if _, ok := value.Interface().(time.Time); ok {
+			return setFormTime(val, value, field)
+		}
+
+		return json.Unmarshal([]byte(val), value.Addr().Interface())
+
+	case reflect.Map:
+		return json.Unmarshal([]byte(val), value.Addr().Interface())
+
+	default:
+		return errUnknownFormType
+	}
+
+	return nil
+}
+
+// setFormTime 按照 field 的 time_format、time_utc 和 time_location tag 解析时间，默认格式是 RFC3339。
+func setFormTime(val string, value reflect.Value, field reflect.StructField) error {
+	format := field.Tag.Get("time_format")
+
+	if format == "" {
+		format = time.RFC3339
+	}
+
+	switch tf := strings.ToLower(format); tf {
+	case "unix", "unixnano":
+		n, err := strconv.ParseInt(val, 10, 0)
+
+		if err != nil {
+			return err
+		}
+
+		d := int64(1)
+
+		if tf == "unixnano" {
+			d = int64(time.Second)
+		}
+
+		value.Set(reflect.ValueOf(time.Unix(n/d, n%d)))
+		return nil
+	}
+
+	if val == "" {
+		value.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+
+	loc := time.Local
+
+	if utc, _ := strconv.ParseBool(field.Tag.Get("time_utc")); utc {
+		loc = time.UTC
+	}
+
+	if name := field.Tag.Get("time_location"); name != "" {
+		l, err := time.LoadLocation(name)
+
+		if err != nil {
+			return err
+		}
+
+		loc = l
+	}
+
+	t, err := time.ParseInLocation(format, val, loc)
+
+	if err != nil {
+		return err
+	}
+
+	value.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// cutTag 用 sep 将 tag 分成两部分，没有 sep 时第二部分为空。
+func cutTag(tag, sep string) (head, tail string) {
+	if idx := strings.Index(tag, sep); idx >= 0 {
+		return tag[:idx], tag[idx+len(sep):]
+	}
+
+	return tag, ""
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testFormEmbedded struct {
+	Page int `form:"page,default=1"`
+}
+
+type testFormNested struct {
+	ID int `form:"nested_id"`
+}
+
+type testFormMissing struct {
+	ID int `form:"missing_id"`
+}
+
+type testFormRequest struct {
+	testFormEmbedded
+
+	Name     string            `form:"name" binding:"required"`
+	Tags     []string          `form:"tag"`
+	Pair     [2]int            `form:"pair"`
+	Enabled  bool              `form:"enabled"`
+	Ratio    float32           `form:"ratio"`
+	Timeout  time.Duration     `form:"timeout"`
+	Since    time.Time         `form:"since" time_format:"2006-01-02" time_utc:"1"`
+	Until    time.Time         `form:"until" time_format:"unix"`
+	Extra    map[string]string `form:"extra"`
+	Nested   *testFormNested
+	Missing  *testFormMissing
+	Ignored  string `form:"-"`
+	Fallback string
+}
+
+func TestBindQuery(t *testing.T) {
+	a := assert.New(t)
+	req := &testFormRequest{}
+	a.NilError(bindQuery(map[string][]string{
+		"name":      {"huandu"},
+		"tag":       {"a", "b"},
+		"pair":      {"1", "2"},
+		"enabled":   {"true"},
+		"ratio":     {"0.5"},
+		"timeout":   {"1.5s"},
+		"since":     {"2020-01-02"},
+		"until":     {"1577934245"},
+		"extra":     {`{"k":"v"}`},
+		"nested_id": {"12"},
+		"-":         {"ignored"},
+		"Ignored":   {"ignored"},
+		"Fallback":  {"field name"},
+	}, req))
+	a.Equal(req, &testFormRequest{
+		testFormEmbedded: testFormEmbedded{Page: 1},
+		Name:             "huandu",
+		Tags:             []string{"a", "b"},
+		Pair:             [2]int{1, 2},
+		Enabled:          true,
+		Ratio:            0.5,
+		Timeout:          1500 * time.Millisecond,
+		Since:            time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		Until:            time.Unix(1577934245, 0),
+		Extra:            map[string]string{"k": "v"},
+		Nested:           &testFormNested{ID: 12},
+		Fallback:         "field name",
+	})
+
+	// 校验规则与 gin 一致。
+	err := bindQuery(map[string][]string{}, &testFormRequest{})
+	a.NonNilError(err)
+	a.Assert(strings.Contains(err.Error(), "'required' tag"))
+
+	a.NonNilError(bindQuery(map[string][]string{"name": {"huandu"}, "pair": {"1"}}, &testFormRequest{}))
+	a.NonNilError(bindQuery(map[string][]string{"name": {"huandu"}, "ratio": {"bad"}}, &testFormRequest{}))
+}
+
+func TestBindJSON(t *testing.T) {
+	a := assert.New(t)
+	req := &testFormRequest{}
+	a.NilError(bindJSON(strings.NewReader(`{"Name":"huandu","Tags":["a"]}`), req))
+	a.Equal(req.Name, "huandu")
+	a.Equal(req.Tags, []string{"a"})
+
+	a.NonNilError(bindJSON(nil, req))
+	a.NonNilError(bindJSON(strings.NewReader(`{"Name":`), req))
+	a.NonNilError(bindJSON(strings.NewReader(`{}`), &testFormRequest{}))
+}
@@ -2,15 +2,14 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
-	"runtime/debug"
+	"strconv"
 	"time"
 
-	"github.com/gin-gonic/gin"
-
 	"github.com/altstory/go-log"
 	"github.com/altstory/go-runner"
 )
@@ -20,9 +19,15 @@ import (
 // Handler 支持的函数签名格式：
 //     - func(ctx context.Context, req *T) (res *U, err error)：最推荐的业务函数签名形式。
 //                                                              其中 `T` 和 `U` 是请求和应答的结构类型。
+//     - func(ctx context.Context, req *T) (res <-chan *U, err error)：流式输出大量数据，详见 Iterator。
+//     - func(ctx context.Context, req *T) (res Iterator, err error)：流式输出大量数据，详见 Iterator。
 //     - func(writer http.ResponseWriter, req *http.Request)：如果需要使用更底层的能力，例如传输文件，可以使用这种形式。
 //                                                            这个签名跟 http.HandlerFunc 一致。
 //     - http.Handler：也可以直接注册一个 http.Handler 实例，应用场景同上。
+//     - func(ctx context.Context, req *T, stream EventStream) error：使用 Server-Sent Events 向客户端推送事件，
+//                                                                   请求参数的解析规则与业务函数一致。
+//     - BusinessFunc：通过 Handle 注册的类型安全的业务函数，与第一种形式等价，但在编译期检查类型。
+//     - 通过 WS 生成的 WebSocket 处理函数。
 type Handler interface{}
 
 var (
@@ -31,11 +36,16 @@ var (
 	typeOfError           = reflect.TypeOf((*error)(nil)).Elem()
 )
 
-func parseHandlersForGin(handlers []Handler) ([]gin.HandlerFunc, error) {
-	hfs := make([]gin.HandlerFunc, 0, len(handlers))
+// handlerParser 是可以自行解析成框架内部处理函数的 Handler，例如 WS 和 Handle 生成的处理函数。
+type handlerParser interface {
+	parse() (handlerFunc, error)
+}
+
+func parseHandlers(handlers []Handler) ([]handlerFunc, error) {
+	hfs := make([]handlerFunc, 0, len(handlers))
 
 	for _, h := range handlers {
-		hf, err := parseHandlerForGin(h)
+		hf, err := parseHandler(h)
 
 		if err != nil {
 			return nil, err
@@ -47,11 +57,16 @@ func parseHandlersForGin(handlers []Handler) ([]gin.HandlerFunc, error) {
 	return hfs, nil
 }
 
-// parseHandlerForGin 将 handler 解析成 gin 需要的处理函数形式。
-func parseHandlerForGin(handler Handler) (gin.HandlerFunc, error) {
+// parseHandler 将 handler 解析成框架内部的处理函数形式。
+func parseHandler(handler Handler) (handlerFunc, error) {
 	if h, ok := handler.(http.Handler); ok {
 		return wrapHTTPHandler(h)
 	}
+
+	if h, ok := handler.(handlerParser); ok {
+		return h.parse()
+	}
+
 	v := reflect.ValueOf(handler)
 	t := v.Type()
 
@@ -69,19 +84,63 @@ func parseHandlerForGin(handler Handler) (gin.HandlerFunc, error) {
 
 	// 当前我们只支持下列形式的自定义 handler：
 	//     - func(ctx context.Context, in T) (out V, err error)
+	//     - func(ctx context.Context, in T) (out <-chan V, err error)
+	//     - func(ctx context.Context, in T) (out Iterator, err error)
+	//     - func(ctx context.Context, in T, stream EventStream) error
+	if in == 3 && out == 1 {
+		return parseEventStreamHandler(v)
+	}
+
 	if in != 2 || out != 2 {
 		return nil, errors.New("go-http: type of the handler is not supported")
 	}
 
-	tIn0 := t.In(0)
-	tIn1 := t.In(1)
+	streaming, ok := businessHandlerType(t)
 
-	if tIn0.Kind() != reflect.Interface {
+	if !ok {
 		return nil, errors.New("go-http: type of the handler is not supported")
 	}
 
-	if !tIn0.Implements(typeOfContext) || !typeOfContext.Implements(tIn0) {
-		return nil, errors.New("go-http: type of the handler is not supported")
+	if streaming {
+		return wrapStreamHandler(v)
+	}
+
+	return wrapBusinessHandler(v)
+}
+
+// IsBusinessHandler 判断 handler 是否是 func(ctx context.Context, req T) (res V, err error) 形式的业务函数，
+// 包括返回 chan 或者 Iterator 的流式业务函数。ctx 和 err 可以是与 context.Context 和 error 等价的接口类型。
+func IsBusinessHandler(handler Handler) bool {
+	if handler == nil {
+		return false
+	}
+
+	if _, ok := handler.(http.Handler); ok {
+		return false
+	}
+
+	t := reflect.TypeOf(handler)
+
+	if t.Kind() != reflect.Func || t.ConvertibleTo(typeOfHTTPHandlerFunc) || t.NumIn() != 2 || t.NumOut() != 2 {
+		return false
+	}
+
+	_, ok := businessHandlerType(t)
+	return ok
+}
+
+// isContextType 判断 t 是否可以作为业务函数的第一个参数，
+// t 必须是与 context.Context 互相兼容的 interface 类型。
+func isContextType(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.Implements(typeOfContext) && typeOfContext.Implements(t)
+}
+
+// businessHandlerType 检查 t 的参数和返回值是否符合业务函数的要求，t 必须是有两个参数和两个返回值的 func。
+func businessHandlerType(t reflect.Type) (streaming, ok bool) {
+	tIn1 := t.In(1)
+
+	if !isContextType(t.In(0)) {
+		return false, false
 	}
 
 	if tIn1.Kind() == reflect.Ptr {
@@ -89,77 +148,66 @@ func parseHandlerForGin(handler Handler) (gin.HandlerFunc, error) {
 	}
 
 	if tIn1.Kind() != reflect.Struct {
-		return nil, errors.New("go-http: type of the handler is not supported")
+		return false, false
 	}
 
 	tOut0 := t.Out(0)
 	tOut1 := t.Out(1)
+	streaming = isStreamResponse(tOut0)
 
 	if tOut0.Kind() == reflect.Ptr {
 		tOut0 = tOut0.Elem()
 	}
 
-	if tOut0.Kind() != reflect.Struct {
-		return nil, errors.New("go-http: type of the handler is not supported")
+	if !streaming && tOut0.Kind() != reflect.Struct {
+		return false, false
 	}
 
 	if tOut1.Kind() != reflect.Interface {
-		return nil, errors.New("go-http: type of the handler is not supported")
+		return false, false
 	}
 
 	if !tOut1.Implements(typeOfError) || !typeOfError.Implements(tOut1) {
-		return nil, errors.New("go-http: type of the handler is not supported")
+		return false, false
 	}
 
-	return wrapBusinessHandler(v)
+	return streaming, true
 }
 
-func wrapHTTPHandler(h http.Handler) (gin.HandlerFunc, error) {
+func wrapHTTPHandler(h http.Handler) (handlerFunc, error) {
 	if h == nil {
 		return nil, errors.New("go-http: handler must be valid")
 	}
 
-	return wrapGinHandlerFunc(gin.WrapH(h)), nil
+	return wrapHandlerFunc(func(c *httpContext) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}), nil
 }
 
-func wrapHTTPHandlerFunc(hf http.HandlerFunc) (gin.HandlerFunc, error) {
+func wrapHTTPHandlerFunc(hf http.HandlerFunc) (handlerFunc, error) {
 	if hf == nil {
 		return nil, errors.New("go-http: handler must be valid")
 	}
 
-	return wrapGinHandlerFunc(gin.WrapF(hf)), nil
+	return wrapHandlerFunc(func(c *httpContext) {
+		hf(c.Writer, c.Request)
+	}), nil
 }
 
-func wrapBusinessHandler(v reflect.Value) (gin.HandlerFunc, error) {
-	in := v.Type().In(1)
-	indirect := false
-
-	if in.Kind() == reflect.Ptr {
-		in = in.Elem()
-		indirect = true
-	}
+func wrapBusinessHandler(v reflect.Value) (handlerFunc, error) {
+	plan := newBindingPlan(v.Type().In(1))
 
-	return wrapGinHandlerFunc(func(c *gin.Context) {
+	return wrapHandlerFunc(func(c *httpContext) {
 		ctx := c.Request.Context()
-		vIn := reflect.New(in)
+		ptr := plan.acquire()
+		defer plan.release(ptr)
 
-		if err := c.BindQuery(vIn.Interface()); err != nil {
-			writeResponse(ctx, c, http.StatusBadRequest, newErrorMsg(ErrCodeBadRequest, fmt.Sprintf("go-http: fail to parse query with error: %v", err)).ToH(nil))
+		if em := plan.bind(c, ptr.Interface()); em != nil {
+			writeEnvelope(ctx, c, bindStatus(em), em, nil)
 			return
 		}
 
-		if c.Request.Method != http.MethodGet && c.ContentType() == gin.MIMEJSON {
-			if err := c.BindJSON(vIn.Interface()); err != nil {
-				writeResponse(ctx, c, http.StatusBadRequest, newErrorMsg(ErrCodeBadRequest, fmt.Sprintf("go-http: invalid request content type or invalid JSON in body with error: %v", err)).ToH(nil))
-				return
-			}
-		}
-
-		if !indirect {
-			vIn = vIn.Elem()
-		}
-
-		args := []reflect.Value{reflect.ValueOf(ctx), vIn}
+		args := []reflect.Value{reflect.ValueOf(ctx), plan.arg(ptr)}
 		returns := v.Call(args)
 
 		var data interface{}
@@ -175,61 +223,121 @@ func wrapBusinessHandler(v reflect.Value) (gin.HandlerFunc, error) {
 
 		if returns[1].IsValid() {
 			err, _ = returns[1].Interface().(error)
+		}
 
-			if err != nil {
-				em, ok := err.(*errorMsg)
+		writeBusinessResponse(ctx, c, data, err)
+	}), nil
+}
 
-				if !ok {
-					writeResponse(ctx, c, http.StatusInternalServerError, newErrorMsg(ErrCodeInvalidError, fmt.Sprintf("go-http: business returns an invalid error: %v", err)).ToH(nil))
-					return
-				}
+// writeBusinessResponse 输出业务函数的返回值，data 是应答，err 是业务函数返回的错误。
+func writeBusinessResponse(ctx context.Context, c *httpContext, data interface{}, err error) {
+	if err != nil {
+		em, ok := err.(*errorMsg)
 
-				writeResponse(ctx, c, http.StatusOK, em.ToH(data))
-				return
-			}
+		if !ok {
+			writeEnvelope(ctx, c, http.StatusInternalServerError, newErrorMsg(ErrCodeInvalidError, fmt.Sprintf("go-http: business returns an invalid error: %v", err)), nil)
+			return
 		}
 
-		writeResponse(ctx, c, http.StatusOK, newErrorMsg(ErrCodeOK, "").ToH(data))
-	}), nil
+		writeEnvelope(ctx, c, http.StatusOK, em, data)
+		return
+	}
+
+	writeEnvelope(ctx, c, http.StatusOK, errorMsgOK, data)
 }
 
+// HeaderTraceID 是用来在服务之间传递 trace id 的 HTTP header。
+const HeaderTraceID = "X-Trace-Id"
+
 type keyStartTimeType struct{}
+type keyTraceIDType struct{}
 
 var (
 	keyStartTime keyStartTimeType
+	keyTraceID   keyTraceIDType
 )
 
-func wrapGinHandlerFunc(fn gin.HandlerFunc) gin.HandlerFunc {
-	return func(c *gin.Context) {
+// TraceID 返回 ctx 中的 trace id，如果 ctx 不是由框架创建的则返回空字符串。
+// 框架会优先使用上游通过 HeaderTraceID 传入的 trace id，如果没有则自动生成一个。
+func TraceID(ctx context.Context) string {
+	traceid, _ := ctx.Value(keyTraceID).(string)
+	return traceid
+}
+
+// maxTraceIDLen 是上游传入的 trace id 的最大长度。
+const maxTraceIDLen = 64
+
+// isValidTraceID 判断 traceid 是否只包含字母、数字和“-”，并且长度在 1 到 maxTraceIDLen 之间。
+func isValidTraceID(traceid string) bool {
+	if traceid == "" || len(traceid) > maxTraceIDLen {
+		return false
+	}
+
+	for i := 0; i < len(traceid); i++ {
+		ch := traceid[i]
+
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func wrapHandlerFunc(fn handlerFunc) handlerFunc {
+	return func(c *httpContext) {
 		// 往 ctx 里面放些东西。
 		now := time.Now()
-		traceid := now.UnixNano()
+		traceid := c.GetHeader(HeaderTraceID)
+
+		// trace id 会原样写入日志，不合法的 trace id 直接丢弃，避免上游伪造日志内容。
+		if !isValidTraceID(traceid) {
+			traceid = strconv.FormatInt(now.UnixNano(), 10)
+		}
+
 		ctx := context.Background()
 		ctx = context.WithValue(ctx, keyStartTime, now)
+		ctx = context.WithValue(ctx, keyTraceID, traceid)
 		ctx = log.WithMoreInfo(ctx,
 			log.Info{Key: "traceid", Value: traceid},
 		)
 		ctx = runner.WithStats(ctx, &runner.Stats{})
+		ctx = withResponse(ctx)
+		ctx = withRequest(ctx, c)
+		ctx = decorateContext(ctx, c)
 
 		defer func() {
 			if r := recover(); r != nil {
-				serverMetrics.Panic.Add(1)
-
-				log.Errorf(ctx, "err=%v||url=%v||method=%v||go-http: caught a panic with call stack\n%v", r, c.Request.URL, c.Request.Method, string(debug.Stack()))
-				writeResponse(ctx, c, http.StatusInternalServerError, newErrorMsg(ErrCodeServerPanic, fmt.Sprintf("go-http: caught a panic [err:%v]", r)).ToH(nil))
+				handlePanic(ctx, c, r)
 			}
 		}()
 
 		c.Request = c.Request.WithContext(ctx)
-		log.Tracef(log.WithTag(ctx, "http.server.in"), "url=%v||method=%v||go-http: request starts",
-			c.Request.URL.Path, c.Request.Method)
+		log.Tracef(log.WithTag(ctx, "http.server.in"), "url=%v||method=%v||proto=%v||go-http: request starts",
+			c.Request.URL.Path, c.Request.Method, c.Request.Proto)
 		fn(c)
 	}
 }
 
-func writeResponse(ctx context.Context, c *gin.Context, status int, data gin.H) {
-	c.JSON(status, data)
+func writeResponse(ctx context.Context, c *httpContext, status int, data map[string]interface{}) {
+	if _, ok := data["now"]; ok {
+		data["now"] = Now(ctx).Format(time.RFC3339)
+	}
+
+	body, err := json.Marshal(data)
+
+	// 应答无法编码说明业务代码有 bug，直接 panic，由 wrapHandlerFunc 统一处理。
+	if err != nil {
+		panic(err)
+	}
 
+	status = applyResponse(ctx, c, status, data["err"])
+	writeJSON(c, status, body)
+	reportResponse(ctx, c, data["err"])
+}
+
+// reportResponse 在请求结束时记录日志和监控，code 是应答中的业务错误码。
+func reportResponse(ctx context.Context, c *httpContext, code interface{}) {
 	start := ctx.Value(keyStartTime).(time.Time)
 	proctime := time.Now().Sub(start)
 
@@ -243,18 +351,24 @@ func writeResponse(ctx context.Context, c *gin.Context, status int, data gin.H)
 	ctx = log.WithMoreInfo(ctx, info...)
 
 	uri := c.Request.URL.Path
-	code, hasErr := data["err"]
+	tag := uri
 	proctimeMS := int64(proctime / time.Millisecond)
 
-	httpMetrics.QPS.AddForTag(uri, 1)
-	httpMetrics.Count.AddForTag(uri, 1)
-	httpMetrics.ProcTime.AddForTag(uri, proctimeMS)
-	httpMetrics.MaxProcTime.AddForTag(uri, proctimeMS)
+	// 没有匹配到路由的请求路径由客户端决定，统一记录在一个标签下，避免监控标签无限增长。
+	if c.FullPath() == "" {
+		tag = noRouteMetricsTag
+	}
+
+	httpMetrics.QPS.AddForTag(tag, 1)
+	httpMetrics.Count.AddForTag(tag, 1)
+	httpMetrics.ProcTime.AddForTag(tag, proctimeMS)
+	httpMetrics.MaxProcTime.AddForTag(tag, proctimeMS)
+	httpMetrics.Protocol.AddForTag(c.Request.Proto, 1)
 
-	if c, ok := code.(int); !hasErr || !ok || c != 0 {
-		httpMetrics.Failure.AddForTag(uri, 1)
+	if c, ok := code.(int); !ok || c != 0 {
+		httpMetrics.Failure.AddForTag(tag, 1)
 	}
 
-	log.Tracef(ctx, "url=%v||method=%v||code=%v||proctime=%.6f||go-http: request ends",
-		uri, c.Request.Method, data["err"], proctime.Seconds())
+	log.Tracef(ctx, "url=%v||method=%v||proto=%v||code=%v||proctime=%.6f||go-http: request ends",
+		uri, c.Request.Method, c.Request.Proto, code, proctime.Seconds())
 }
@@ -3,7 +3,11 @@ package server
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/huandu/go-assert"
 )
 
 type validBizSt1 struct{ Foo int }
@@ -26,7 +30,7 @@ func validBizFunc7(writer http.ResponseWriter, req *http.Request)
 func TestParseValidBizFuncs(t *testing.T) {
 	validFuncs := []Handler{validBizFunc1, validBizFunc2, validBizFunc3, validBizFunc4, validBizFunc5,
 		validBizFunc6, validBizFunc7}
-	hs, err := parseHandlersForGin(validFuncs)
+	hs, err := parseHandlers(validFuncs)
 
 	if err != nil {
 		t.Fatalf("fail to parse handlers [err:%v]", err)
@@ -57,10 +61,62 @@ func TestParseInvalidBizFuncs(t *testing.T) {
 		invalidBizFunc11, invalidBizFunc12, invalidBizFunc13}
 
 	for _, f := range invalidFuncs {
-		_, err := parseHandlerForGin(f)
+		_, err := parseHandler(f)
 
 		if err == nil {
 			t.Fatalf("f should be invalid.")
 		}
 	}
 }
+
+func TestTraceID(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+	a.NilError(s.AddRoutes(RouteList{
+		R("/traceid", GET, func(ctx context.Context, req *validBizSt1) (*validBizSt2, error) {
+			return &validBizSt2{Bar: TraceID(ctx)}, nil
+		}),
+	}))
+	cases := []struct {
+		traceid string
+		valid   bool
+	}{
+		{"trace-ID-123", true},
+		{strings.Repeat("a", 64), true},
+		{"", false},
+		{strings.Repeat("a", 65), false},
+		{"trace||level=fatal", false},
+		{"trace\nid", false},
+		{"trace id", false},
+	}
+
+	for i, c := range cases {
+		a.Use(i, c)
+		r := httptest.NewRequest(http.MethodGet, "/traceid", nil)
+		r.Header.Set(HeaderTraceID, c.traceid)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		a.Equal(w.Code, http.StatusOK)
+
+		if c.valid {
+			a.Assert(strings.Contains(w.Body.String(), `"Bar":"`+c.traceid+`"`))
+		} else {
+			a.Assert(!strings.Contains(w.Body.String(), `"Bar":"`+c.traceid+`"`))
+			a.Assert(!strings.Contains(w.Body.String(), `"Bar":""`))
+		}
+	}
+}
+
+func TestIsBusinessHandler(t *testing.T) {
+	a := assert.New(t)
+
+	for i, h := range []Handler{validBizFunc1, validBizFunc3, validBizFunc5, validBizFunc6} {
+		a.Use(i)
+		a.Assert(IsBusinessHandler(h))
+	}
+
+	for i, h := range []Handler{nil, 123, validBizFunc7, http.NotFoundHandler(), invalidBizFunc3, invalidBizFunc7, invalidBizFunc9, invalidBizFunc12} {
+		a.Use(i)
+		a.Assert(!IsBusinessHandler(h))
+	}
+}
@@ -2,7 +2,8 @@ package server
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/altstory/go-log"
 	"github.com/altstory/go-runner"
@@ -11,26 +12,88 @@ import (
 // Hook 是一个 HTTP server 状态回调函数。
 type Hook func(ctx context.Context, s *Server) error
 
+const defaultSection = "http.server"
+
+// NamedServer 代表一个通过配置文件自动启动的 HTTP server。
+//
+// 默认 HTTP server 的配置放在 `[http.server]`，
+// 通过 Named 得到的具名 HTTP server 的配置放在 `[http.server.<name>]`。
+type NamedServer struct {
+	name    string
+	section string
+
+	mu         sync.Mutex
+	startHooks []Hook
+	server     *Server
+}
+
 var (
-	startHooks    []Hook
-	defaultServer *Server
+	defaultServer = newNamedServer("", defaultSection)
+
+	namedServersMu sync.Mutex
+	namedServers   = map[string]*NamedServer{}
 )
 
 func init() {
-	runner.AddServer("http.server", func(ctx context.Context, config *Config) error {
+	defaultServer.register()
+}
+
+// Named 返回名字为 name 的 HTTP server，这个 server 使用配置文件中 `[http.server.<name>]` 的配置。
+// 同一个 name 多次调用会返回同一个 server。
+//
+// 与默认 HTTP server 一样，只有注册了 hook 或者路由，具名 server 才会启动。
+// 这个函数应该在 runner.Main 之前调用，一般放在 init 或 main 函数里。
+func Named(name string) *NamedServer {
+	if name == "" {
+		return defaultServer
+	}
+
+	namedServersMu.Lock()
+	defer namedServersMu.Unlock()
+
+	if ns, ok := namedServers[name]; ok {
+		return ns
+	}
+
+	ns := newNamedServer(name, defaultSection+"."+name)
+	ns.register()
+	namedServers[name] = ns
+	return ns
+}
+
+func newNamedServer(name, section string) *NamedServer {
+	return &NamedServer{
+		name:    name,
+		section: section,
+	}
+}
+
+func (ns *NamedServer) register() {
+	runner.AddServer(ns.section, func(ctx context.Context, config *Config) error {
+		ns.mu.Lock()
+		hooks := ns.startHooks
+		ns.mu.Unlock()
+
 		// 没有注册 hook 则直接跳过服务初始化。
-		if len(startHooks) == 0 {
+		if len(hooks) == 0 {
 			return nil
 		}
 
 		if config == nil {
-			return errors.New("go-http: missing http server config")
+			return fmt.Errorf("go-http: missing http server config [section:%v]", ns.section)
 		}
 
 		s := New(config)
-		defaultServer = s
 
-		for _, h := range startHooks {
+		ns.mu.Lock()
+		ns.server = s
+		ns.mu.Unlock()
+
+		if s.err != nil {
+			return s.err
+		}
+
+		for _, h := range hooks {
 			if err := h(ctx, s); err != nil {
 				return err
 			}
@@ -40,20 +103,27 @@ func init() {
 	})
 }
 
+// Name 返回 server 的名字，默认 HTTP server 的名字是空字符串。
+func (ns *NamedServer) Name() string {
+	return ns.name
+}
+
 // OnStart 注册一个回调，这个回调会在 HTTP server 初始化完成后且启动之前执行。
-func OnStart(hook Hook) {
+func (ns *NamedServer) OnStart(hook Hook) {
 	if hook == nil {
 		return
 	}
 
-	startHooks = append(startHooks, hook)
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.startHooks = append(ns.startHooks, hook)
 }
 
-// AddRoutes 向默认 HTTP server 注册路由。
-func AddRoutes(routes Routes) {
-	OnStart(func(ctx context.Context, s *Server) error {
+// AddRoutes 向 HTTP server 注册路由。
+func (ns *NamedServer) AddRoutes(routes Routes) {
+	ns.OnStart(func(ctx context.Context, s *Server) error {
 		if err := s.AddRoutes(routes); err != nil {
-			log.Errorf(ctx, "err=%v||routes=%v||go-http: fail to add routes", err, routes)
+			log.Errorf(ctx, "err=%v||server=%v||routes=%v||go-http: fail to add routes", err, ns.name, routes)
 			return err
 		}
 
@@ -61,11 +131,30 @@ func AddRoutes(routes Routes) {
 	})
 }
 
-// Shutdown 关闭当前服务。
-func Shutdown(ctx context.Context) error {
-	if defaultServer == nil {
+// Shutdown 关闭 HTTP server，如果 server 还没有启动则什么都不做。
+func (ns *NamedServer) Shutdown(ctx context.Context) error {
+	ns.mu.Lock()
+	s := ns.server
+	ns.mu.Unlock()
+
+	if s == nil {
 		return nil
 	}
 
+	return s.Shutdown(ctx)
+}
+
+// OnStart 注册一个回调，这个回调会在默认 HTTP server 初始化完成后且启动之前执行。
+func OnStart(hook Hook) {
+	defaultServer.OnStart(hook)
+}
+
+// AddRoutes 向默认 HTTP server 注册路由。
+func AddRoutes(routes Routes) {
+	defaultServer.AddRoutes(routes)
+}
+
+// Shutdown 关闭当前服务。
+func Shutdown(ctx context.Context) error {
 	return defaultServer.Shutdown(ctx)
 }
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestNamedServer(t *testing.T) {
+	a := assert.New(t)
+	admin := Named("test-admin")
+	a.Equal(admin.Name(), "test-admin")
+	a.Equal(admin.section, "http.server.test-admin")
+	a.Assert(Named("test-admin") == admin)
+	a.Assert(Named("") == defaultServer)
+	a.Assert(Named("test-public") != admin)
+
+	admin.OnStart(nil)
+	a.Equal(len(admin.startHooks), 0)
+
+	admin.AddRoutes(RouteList{
+		R("login", POST, testLogin),
+	})
+	a.Equal(len(admin.startHooks), 1)
+	a.Equal(len(defaultServer.startHooks), 0)
+
+	// server 还没有启动，Shutdown 什么都不做。
+	a.NilError(admin.Shutdown(context.Background()))
+}
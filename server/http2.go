@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP/2 协议允许的 frame 大小范围，详见 RFC 7540 6.5.2。
+const (
+	minHTTP2ReadFrameSize = 1 << 14
+	maxHTTP2ReadFrameSize = 1<<24 - 1
+)
+
+// h2cShutdownPollInterval 是 Shutdown 时检查 h2c 连接是否都已经关闭的间隔。
+const h2cShutdownPollInterval = 50 * time.Millisecond
+
+// configureHTTP2 根据 config 设置 server 的 HTTP/2 参数，配置错误时返回 error。
+//
+// 如果开启了 TLS，HTTP/2 会通过 ALPN 协商；
+// 如果没有开启 TLS 但开启了 H2C，则允许客户端直接使用明文 HTTP/2 或者通过 Upgrade 切换到 HTTP/2，
+// 这些连接会记录在 conns 里面，以便在 Shutdown 时关闭。
+func configureHTTP2(server *http.Server, config *Config, conns *h2cConnSet) error {
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return errors.New("go-http: tls_cert_file and tls_key_file must be set at the same time")
+	}
+
+	if n := config.HTTP2MaxReadFrameSize; n != 0 && (n < minHTTP2ReadFrameSize || n > maxHTTP2ReadFrameSize) {
+		return fmt.Errorf("go-http: invalid http2_max_read_frame_size [value:%v] [min:%v] [max:%v]", n, minHTTP2ReadFrameSize, maxHTTP2ReadFrameSize)
+	}
+
+	h2s := &http2.Server{
+		MaxConcurrentStreams: config.HTTP2MaxConcurrentStreams,
+		MaxReadFrameSize:     config.HTTP2MaxReadFrameSize,
+		IdleTimeout:          config.IdleTimeout,
+	}
+
+	if config.TLSCertFile != "" {
+		// 提前加载证书，避免服务到 Serve 的时候才发现证书有问题。
+		if _, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile); err != nil {
+			return fmt.Errorf("go-http: fail to load TLS certificate [cert:%v] [key:%v] [err:%v]", config.TLSCertFile, config.TLSKeyFile, err)
+		}
+
+		return http2.ConfigureServer(server, h2s)
+	}
+
+	if config.H2C {
+		// ConfigureServer 会让 http.Server.Shutdown 向所有 HTTP/2 连接发送 GOAWAY，h2c 连接也需要。
+		if err := http2.ConfigureServer(server, h2s); err != nil {
+			return err
+		}
+
+		server.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, keyConn, c)
+		}
+		server.Handler = conns.track(h2c.NewHandler(server.Handler, h2s))
+	}
+
+	return nil
+}
+
+type keyConnType struct{}
+
+var keyConn keyConnType
+
+// h2cConnSet 记录一个 Server 所有 h2c 连接。
+// h2c 连接是从 http.Server 接管过来的，http.Server.Shutdown 既不会等待也不会关闭这些连接。
+type h2cConnSet struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// track 在 h2c 连接的整个生命周期里记录这个连接，h2c 的 ServeHTTP 会一直阻塞到连接关闭。
+func (set *h2cConnSet) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, ok := r.Context().Value(keyConn).(net.Conn); ok && isH2C(r) {
+			set.add(conn)
+			defer set.remove(conn)
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (set *h2cConnSet) add(conn net.Conn) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if set.conns == nil {
+		set.conns = map[net.Conn]struct{}{}
+	}
+
+	set.conns[conn] = struct{}{}
+}
+
+func (set *h2cConnSet) remove(conn net.Conn) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	delete(set.conns, conn)
+}
+
+func (set *h2cConnSet) len() int {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return len(set.conns)
+}
+
+// shutdown 等待所有 h2c 连接处理完请求后自行关闭，ctx 结束时强制关闭剩下的连接。
+// 调用之前应该先调用 http.Server.Shutdown，让这些连接收到 GOAWAY。
+func (set *h2cConnSet) shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(h2cShutdownPollInterval)
+	defer ticker.Stop()
+
+	for set.len() > 0 {
+		select {
+		case <-ctx.Done():
+			set.closeAll()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (set *h2cConnSet) closeAll() {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	for conn := range set.conns {
+		conn.Close()
+	}
+}
+
+// isH2C 判断 r 是否是 h2c 连接的开始，包括直接使用 HTTP/2 和通过 Upgrade 切换到 HTTP/2 两种情况。
+func isH2C(r *http.Request) bool {
+	if r.Method == "PRI" && r.URL.Path == "*" && r.Proto == "HTTP/2.0" {
+		return true
+	}
+
+	return httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "h2c")
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+	"golang.org/x/net/http2"
+)
+
+func TestServerH2C(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		PingURI:                   "/ping",
+		H2C:                       true,
+		HTTP2MaxConcurrentStreams: 10,
+	})
+	s.AddRoutes(RouteList{
+		R("login", POST, testLogin),
+	})
+	testServer := httptest.NewServer(s.Handler())
+	defer testServer.Close()
+
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+	resp, err := client.Post(testServer.URL+"/login", "application/json", toJSON(m{
+		"username": testUsername,
+		"passport": testPassword,
+	}))
+	a.NilError(err)
+	a.Equal(resp.Proto, "HTTP/2.0")
+	validateResponse(a, resp)
+
+	// 普通的 HTTP/1.1 客户端依然可以正常访问。
+	resp, err = http.Get(testServer.URL + "/ping")
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.Proto, "HTTP/1.1")
+	a.Equal(resp.StatusCode, http.StatusOK)
+}
+
+func TestServerH2CShutdown(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		H2C: true,
+	})
+	started := make(chan bool)
+	a.NilError(s.AddRoutes(RouteList{
+		R("/block", GET, func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+		}),
+	}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NilError(err)
+	go s.ServeListener(l)
+
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		resp, err := client.Get("http://" + l.Addr().String() + "/block")
+
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		errs <- err
+	}()
+	<-started
+
+	// 处理中的 h2c 请求不会结束，超时之后连接会被强制关闭。
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	a.Equal(s.Shutdown(ctx), context.DeadlineExceeded)
+
+	select {
+	case err := <-errs:
+		a.NonNilError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("h2c connection is not closed")
+	}
+}
+
+func TestServerHTTP2InvalidConfig(t *testing.T) {
+	a := assert.New(t)
+	configs := []*Config{
+		{TLSCertFile: "cert.pem"},
+		{TLSCertFile: "not-exist.pem", TLSKeyFile: "not-exist.key"},
+		{H2C: true, HTTP2MaxReadFrameSize: 1024},
+	}
+
+	for i, config := range configs {
+		a.Use(i)
+		s := New(config)
+		a.NonNilError(s.Serve())
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 监听地址支持的协议前缀。
+const (
+	schemeTCP     = "tcp"
+	schemeTCP4    = "tcp4"
+	schemeTCP6    = "tcp6"
+	schemeUnix    = "unix"
+	schemeFD      = "fd"
+	schemeSystemd = "systemd"
+)
+
+// systemd socket activation 相关的环境变量，详见 sd_listen_fds(3)。
+const (
+	envListenPID     = "LISTEN_PID"
+	envListenFDs     = "LISTEN_FDS"
+	envListenFDNames = "LISTEN_FDNAMES"
+
+	listenFDsStart = 3
+)
+
+// inheritedListener 是一个从父进程继承来的 listener。
+type inheritedListener struct {
+	fd       int
+	name     string
+	listener net.Listener
+}
+
+var (
+	inheritedOnce      sync.Once
+	inheritedListeners []*inheritedListener
+	inheritedErr       error
+)
+
+// listen 根据 addr 创建 listener。
+//
+// addr 支持以下几种格式：
+//     - "host:port" 或 "tcp://host:port"：监听 TCP 地址，也可以用 tcp4/tcp6 限定协议版本；
+//     - "unix:///path/to/file.sock?mode=0660"：监听 Unix domain socket，mode 是可选的文件权限；
+//     - "fd://3"：使用从父进程继承的文件描述符；
+//     - "systemd://" 或 "systemd://name"：使用 systemd socket activation 传入的所有 fd 或指定名字的 fd。
+func listen(addr string) ([]net.Listener, error) {
+	// 热升级启动的进程会从父进程继承 listener，这些 listener 以地址配置命名。
+	if ls, err := namedListeners(listenerName(addr)); err != nil {
+		return nil, err
+	} else if len(ls) > 0 {
+		return ls, nil
+	}
+
+	if !strings.Contains(addr, "://") {
+		l, err := net.Listen(schemeTCP, addr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []net.Listener{l}, nil
+	}
+
+	u, err := url.Parse(addr)
+
+	if err != nil {
+		return nil, fmt.Errorf("go-http: invalid listen address [addr:%v] [err:%v]", addr, err)
+	}
+
+	switch u.Scheme {
+	case schemeTCP, schemeTCP4, schemeTCP6:
+		l, err := net.Listen(u.Scheme, u.Host)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []net.Listener{l}, nil
+
+	case schemeUnix:
+		l, err := listenUnix(u)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []net.Listener{l}, nil
+
+	case schemeFD:
+		fd, err := strconv.Atoi(u.Host)
+
+		if err != nil {
+			return nil, fmt.Errorf("go-http: invalid fd in listen address [addr:%v]", addr)
+		}
+
+		l, err := inheritListener(fd)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []net.Listener{l}, nil
+
+	case schemeSystemd:
+		return systemdListeners(u.Host)
+	}
+
+	return nil, fmt.Errorf("go-http: unsupported listen address [addr:%v]", addr)
+}
+
+func listenUnix(u *url.URL) (net.Listener, error) {
+	path := u.Path
+
+	if u.Host != "" {
+		path = u.Host + path
+	}
+
+	if path == "" {
+		return nil, errors.New("go-http: missing path in unix socket address")
+	}
+
+	var mode os.FileMode
+
+	if m := u.Query().Get("mode"); m != "" {
+		perm, err := strconv.ParseUint(m, 8, 32)
+
+		if err != nil {
+			return nil, fmt.Errorf("go-http: invalid unix socket mode [mode:%v]", m)
+		}
+
+		mode = os.FileMode(perm)
+	}
+
+	// 上次进程异常退出可能会残留 socket 文件，需要先删除才能重新监听。
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	l, err := net.Listen(schemeUnix, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// loadInheritedListeners 读取 systemd 协议传入的所有 fd。
+// 每个 fd 只能被使用一次，所以结果会被缓存起来。
+func loadInheritedListeners() ([]*inheritedListener, error) {
+	inheritedOnce.Do(func() {
+		fds := os.Getenv(envListenFDs)
+
+		if fds == "" {
+			return
+		}
+
+		if pid := os.Getenv(envListenPID); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+			return
+		}
+
+		n, err := strconv.Atoi(fds)
+
+		if err != nil || n < 0 {
+			inheritedErr = fmt.Errorf("go-http: invalid %v [value:%v]", envListenFDs, fds)
+			return
+		}
+
+		var names []string
+
+		if v := os.Getenv(envListenFDNames); v != "" {
+			names = strings.Split(v, ":")
+		}
+
+		for i := 0; i < n; i++ {
+			fd := listenFDsStart + i
+			name := ""
+
+			if i < len(names) {
+				name = names[i]
+			}
+
+			f := os.NewFile(uintptr(fd), name)
+			l, err := net.FileListener(f)
+			f.Close()
+
+			if err != nil {
+				inheritedErr = fmt.Errorf("go-http: fail to inherit listener [fd:%v] [err:%v]", fd, err)
+				return
+			}
+
+			inheritedListeners = append(inheritedListeners, &inheritedListener{
+				fd:       fd,
+				name:     name,
+				listener: l,
+			})
+		}
+	})
+
+	return inheritedListeners, inheritedErr
+}
+
+func inheritListener(fd int) (net.Listener, error) {
+	inherited, err := loadInheritedListeners()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, il := range inherited {
+		if il.fd == fd {
+			return il.listener, nil
+		}
+	}
+
+	// fd 不是通过 systemd 协议传入的，直接尝试使用。
+	f := os.NewFile(uintptr(fd), "fd"+strconv.Itoa(fd))
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+func systemdListeners(name string) ([]net.Listener, error) {
+	listeners, err := namedListeners(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("go-http: no listener is passed by systemd [name:%v]", name)
+	}
+
+	return listeners, nil
+}
+
+// namedListeners 返回继承来的、名字是 name 的所有 listener，如果 name 为空则返回所有 listener。
+func namedListeners(name string) ([]net.Listener, error) {
+	inherited, err := loadInheritedListeners()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []net.Listener
+
+	for _, il := range inherited {
+		if name == "" || il.name == name {
+			listeners = append(listeners, il.listener)
+		}
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-http-test")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "server.sock")
+	ls, err := listen("unix://" + path + "?mode=0600")
+	a.NilError(err)
+	a.Equal(len(ls), 1)
+
+	fi, err := os.Stat(path)
+	a.NilError(err)
+	a.Equal(fi.Mode().Perm(), os.FileMode(0600))
+
+	s := New(&Config{
+		PingURI: "/ping",
+	})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ServeListener(ls[0])
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial("unix", path)
+			},
+		},
+	}
+	resp, err := client.Get("http://unix/ping")
+	a.NilError(err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	a.Equal(string(body), "OK")
+
+	a.NilError(s.Shutdown(context.Background()))
+	a.NilError(<-errs)
+}
+
+func TestListenInvalidAddrs(t *testing.T) {
+	a := assert.New(t)
+
+	for _, addr := range []string{"foo://bar", "fd://abc", "unix://", "unix:///tmp/x.sock?mode=999"} {
+		_, err := listen(addr)
+		a.Assert(err != nil)
+	}
+}
+
+func TestListenAddrs(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(listenAddrs(&Config{}), []string{":http"})
+	a.Equal(listenAddrs(&Config{
+		Addr:   ":8080",
+		Listen: []string{"unix:///tmp/foo.sock"},
+	}), []string{":8080", "unix:///tmp/foo.sock"})
+}
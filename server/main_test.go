@@ -7,5 +7,12 @@ import (
 
 func TestMain(m *testing.M) {
 	initMetrics()
+
+	// 热升级测试启动的子进程只需要扮演新进程的角色，不执行任何测试。
+	if addr := os.Getenv(envTestUpgradeAddr); addr != "" && os.Getenv(envUpgradeReadyFD) != "" {
+		runUpgradedTestServer(addr)
+		os.Exit(0)
+	}
+
 	os.Exit(m.Run())
 }
@@ -10,11 +10,14 @@ import (
 
 var (
 	httpMetrics struct {
-		QPS, ProcTime, MaxProcTime, Count, Failure *metrics.Metric
+		QPS, ProcTime, MaxProcTime, Count, Failure, Protocol, Deprecated, Panic *metrics.Metric
 	}
 	serverMetrics struct {
 		Goroutine, Panic *metrics.Metric
 	}
+	wsMetrics struct {
+		Connect, Connections, Message *metrics.Metric
+	}
 )
 
 func init() {
@@ -46,6 +49,18 @@ func initMetrics() {
 		Category: "api_failure",
 		Method:   metrics.Sum,
 	})
+	httpMetrics.Protocol = metrics.Define(&metrics.Def{
+		Category: "api_protocol",
+		Method:   metrics.Sum,
+	})
+	httpMetrics.Deprecated = metrics.Define(&metrics.Def{
+		Category: "api_deprecated",
+		Method:   metrics.Sum,
+	})
+	httpMetrics.Panic = metrics.Define(&metrics.Def{
+		Category: "api_panic",
+		Method:   metrics.Sum,
+	})
 
 	serverMetrics.Goroutine = metrics.Define(&metrics.Def{
 		Category: "server_goroutine",
@@ -55,4 +70,17 @@ func initMetrics() {
 		Category: "server_panic",
 		Method:   metrics.Sum,
 	})
+
+	wsMetrics.Connect = metrics.Define(&metrics.Def{
+		Category: "ws_connect",
+		Method:   metrics.Sum,
+	})
+	wsMetrics.Connections = metrics.Define(&metrics.Def{
+		Category: "ws_connections",
+		Method:   metrics.Maximum,
+	})
+	wsMetrics.Message = metrics.Define(&metrics.Def{
+		Category: "ws_message",
+		Method:   metrics.Sum,
+	})
 }
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// noRouteMetricsTag 是没有匹配到路由的请求在监控中使用的标签。
+const noRouteMetricsTag = "<no_route>"
+
+// serveNoRoute 处理没有匹配到任何路由的请求。
+//
+// 路由引擎已经按照 GET 路由处理了 HEAD 请求，框架按照下面的规则处理剩下的请求：
+//     - 路径能匹配其他路由的 OPTIONS 请求，返回 204 和 Allow header；
+//     - 路径能匹配其他路由的请求，返回 405 和 Allow header，错误码是 ErrCodeMethodNotAllowed；
+//     - 其他请求返回 404，错误码是 ErrCodeNotFound。
+func (s *Server) serveNoRoute(c *httpContext) {
+	writeNoRoute(c)
+}
+
+var writeNoRoute = wrapHandlerFunc(func(c *httpContext) {
+	ctx := c.Request.Context()
+	methods := serverFrom(c).engine.Methods(c.Request.URL.Path)
+
+	if len(methods) == 0 {
+		writeEnvelope(ctx, c, http.StatusNotFound, newErrorMsg(ErrCodeNotFound, fmt.Sprintf("go-http: no route matches the path [path:%v]", c.Request.URL.Path)), nil)
+		return
+	}
+
+	c.Writer.Header().Set("Allow", allowHeader(methods))
+
+	if c.Request.Method == http.MethodOptions {
+		writeEnvelope(ctx, c, http.StatusNoContent, errorMsgOK, nil)
+		return
+	}
+
+	writeEnvelope(ctx, c, http.StatusMethodNotAllowed, newErrorMsg(ErrCodeMethodNotAllowed, fmt.Sprintf("go-http: method is not allowed [method:%v] [path:%v]", c.Request.Method, c.Request.URL.Path)), nil)
+})
+
+// allowHeader 生成 Allow header，GET 路由同时接受 HEAD 请求，所有路由都接受 OPTIONS 请求。
+func allowHeader(methods []string) string {
+	allowed := make([]string, 0, len(methods)+2)
+	allowed = append(allowed, methods...)
+
+	if containsString(methods, http.MethodGet) && !containsString(methods, http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+
+	if !containsString(methods, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+
+	return strings.Join(allowed, ", ")
+}
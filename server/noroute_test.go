@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testNoRouteResponse struct {
+	Name string `json:"name"`
+}
+
+func testNoRouteHandler(ctx context.Context, req *struct{}) (*testNoRouteResponse, error) {
+	return &testNoRouteResponse{
+		Name: "foo",
+	}, nil
+}
+
+func TestNoRoute(t *testing.T) {
+	a := assert.New(t)
+
+	for _, engine := range testEngines {
+		a.Use(engine)
+		s := New(&Config{
+			Engine: engine,
+		})
+		a.NilError(s.AddRoutes(RouteMap{
+			"/user": RouteList{
+				R("/:id", GET, testNoRouteHandler),
+				R("/:id", DELETE, testNoRouteHandler),
+				R("/:id/profile", POST, testNoRouteHandler),
+				R("/:id/profile", OPTIONS, func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusTeapot)
+				}),
+				R("/:id/avatar", GET, testNoRouteHandler),
+				R("/:id/avatar", HEAD, func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNoContent)
+				}),
+			},
+		}))
+
+		call := func(method, uri string) (*httptest.ResponseRecorder, map[string]interface{}) {
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, httptest.NewRequest(method, uri, nil))
+			res := map[string]interface{}{}
+
+			if w.Body.Len() != 0 {
+				a.NilError(json.Unmarshal(w.Body.Bytes(), &res))
+			}
+
+			return w, res
+		}
+
+		w, res := call(http.MethodGet, "/not/found")
+		a.Equal(w.Code, http.StatusNotFound)
+		a.Equal(res["err"], float64(ErrCodeNotFound))
+		a.Equal(w.Header().Get("Allow"), "")
+
+		w, res = call(http.MethodPut, "/user/12")
+		a.Equal(w.Code, http.StatusMethodNotAllowed)
+		a.Equal(res["err"], float64(ErrCodeMethodNotAllowed))
+		a.Equal(w.Header().Get("Allow"), "GET, DELETE, HEAD, OPTIONS")
+
+		w, _ = call(http.MethodOptions, "/user/12")
+		a.Equal(w.Code, http.StatusNoContent)
+		a.Equal(w.Header().Get("Allow"), "GET, DELETE, HEAD, OPTIONS")
+		a.Equal(w.Body.Len(), 0)
+
+		// 已经注册的 OPTIONS 路由优先。
+		w, _ = call(http.MethodOptions, "/user/12/profile")
+		a.Equal(w.Code, http.StatusTeapot)
+
+		w, _ = call(http.MethodGet, "/user/12/profile")
+		a.Equal(w.Code, http.StatusMethodNotAllowed)
+		a.Equal(w.Header().Get("Allow"), "POST, OPTIONS")
+
+		// HEAD 请求按照 GET 请求处理，但不输出 body。
+		w, _ = call(http.MethodHead, "/user/12")
+		a.Equal(w.Code, http.StatusOK)
+		a.Equal(w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+		a.Equal(w.Body.Len(), 0)
+
+		// 已经注册的 HEAD 路由优先。
+		w, _ = call(http.MethodHead, "/user/12/avatar")
+		a.Equal(w.Code, http.StatusNoContent)
+	}
+}
+
+func TestAllowHeader(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(allowHeader([]string{http.MethodPost}), "POST, OPTIONS")
+	a.Equal(allowHeader([]string{http.MethodGet, http.MethodHead}), "GET, HEAD, OPTIONS")
+	a.Equal(allowHeader(anyMethods), "GET, POST, PUT, PATCH, HEAD, OPTIONS, DELETE, CONNECT, TRACE")
+}
@@ -0,0 +1,415 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/altstory/go-log"
+	"github.com/altstory/go-runner"
+)
+
+const openAPIVersion = "3.0.3"
+
+// OpenAPIInfo 是 OpenAPI 文档的基本信息。
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       *OpenAPIInfo               `json:"info"`
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components *openAPIComponents         `json:"components,omitempty"`
+	ErrCodes   map[string]string          `json:"x-error-codes,omitempty"`
+
+	schemas map[reflect.Type]string
+	names   map[string]reflect.Type
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas,omitempty"`
+}
+
+type openAPIPathItem map[string]*openAPIOperation
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Content map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+}
+
+var (
+	typeOfTime  = reflect.TypeOf(time.Time{})
+	typeOfBytes = reflect.TypeOf([]byte(nil))
+
+	// ANY 路由在文档里面展开成以下几种请求方法。
+	openAPIAnyMethods = []Method{GET, POST, PUT, PATCH, DELETE}
+)
+
+// GenerateOpenAPI 根据 routes 生成 OpenAPI 3 文档，返回 JSON 格式的文档内容。
+// 这个函数可以在编译期间调用，用来导出接口文档。
+//
+// 业务函数的请求参数按照以下规则生成：
+//     - GET 请求的所有参数都通过 query 传递，参数名来自 `form` tag，没有 tag 则使用字段名；
+//     - 其他请求中，设置了 `form` tag 的字段通过 query 传递，其他字段按照 `json` tag 放在 JSON body 里面。
+//
+// 业务函数的应答会被包装在 `{"err":0,"msg":"","now":"","data":{}}` 结构的 data 字段里面。
+func GenerateOpenAPI(routes Routes, info *OpenAPIInfo) ([]byte, error) {
+	list, err := ListRoutes(routes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return generateOpenAPI(list, info)
+}
+
+func generateOpenAPI(routes []*RouteInfo, info *OpenAPIInfo) ([]byte, error) {
+	if info == nil {
+		info = &OpenAPIInfo{}
+	}
+
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Paths:   map[string]openAPIPathItem{},
+		Components: &openAPIComponents{
+			Schemas: map[string]*openAPISchema{},
+		},
+		schemas: map[reflect.Type]string{},
+		names:   map[string]reflect.Type{},
+	}
+
+	codes, descriptions := registeredErrCodes()
+	doc.ErrCodes = make(map[string]string, len(codes))
+
+	for i, code := range codes {
+		doc.ErrCodes[fmt.Sprint(code)] = descriptions[i]
+	}
+
+	for _, route := range routes {
+		p, params := openAPIPath(route.Path)
+		item, ok := doc.Paths[p]
+
+		if !ok {
+			item = openAPIPathItem{}
+			doc.Paths[p] = item
+		}
+
+		methods := []Method{route.Method}
+
+		if route.Method == ANY {
+			methods = openAPIAnyMethods
+		}
+
+		for _, method := range methods {
+			item[strings.ToLower(method.String())] = doc.operation(route, method, params)
+		}
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// serveOpenAPI 根据当前已注册的路由输出接口文档。
+func (s *Server) serveOpenAPI(c *httpContext) {
+	meta := runner.Meta()
+	data, err := generateOpenAPI(s.Routes(), &OpenAPIInfo{
+		Title:   meta.Project,
+		Version: meta.GitRevision,
+	})
+
+	if err != nil {
+		log.Errorf(c.Request.Context(), "err=%v||go-http: fail to generate OpenAPI document", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// openAPIPath 将 gin 风格的路径参数（:id 和 *path）转换成 OpenAPI 的格式。
+func openAPIPath(p string) (string, []string) {
+	segments := strings.Split(p, "/")
+	var params []string
+
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func (doc *openAPIDocument) operation(route *RouteInfo, method Method, pathParams []string) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: openAPIOperationID(route.Handler, method, route.Method == ANY),
+		Responses:   map[string]*openAPIResponse{},
+		Deprecated:  route.Deprecated,
+	}
+
+	for _, name := range pathParams {
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &openAPISchema{Type: "string"},
+		})
+	}
+
+	// 非业务函数无法得知参数和应答格式。
+	if route.Request == nil || route.Response == nil {
+		op.Responses["default"] = &openAPIResponse{
+			Description: "raw HTTP response",
+		}
+		return op
+	}
+
+	body := &openAPISchema{
+		Type:       "object",
+		Properties: map[string]*openAPISchema{},
+	}
+
+	for _, field := range structFields(route.Request) {
+		if method == GET || field.Tag.Get("form") != "" {
+			name := tagName(field, "form")
+
+			if name == "" {
+				continue
+			}
+
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name:   name,
+				In:     "query",
+				Schema: doc.schema(field.Type),
+			})
+			continue
+		}
+
+		if name := tagName(field, "json"); name != "" {
+			body.Properties[name] = doc.schema(field.Type)
+		}
+	}
+
+	if method != GET && len(body.Properties) > 0 {
+		op.RequestBody = &openAPIRequestBody{
+			Content: map[string]*openAPIMediaType{
+				"application/json": {Schema: body},
+			},
+		}
+	}
+
+	op.Responses[fmt.Sprint(http.StatusOK)] = &openAPIResponse{
+		Description: "business response",
+		Content: map[string]*openAPIMediaType{
+			"application/json": {Schema: doc.envelope(route.Response)},
+		},
+	}
+	return op
+}
+
+func openAPIOperationID(handler string, method Method, any bool) string {
+	id := handler
+
+	if idx := strings.LastIndex(id, "/"); idx >= 0 {
+		id = id[idx+1:]
+	}
+
+	if any {
+		id += "_" + strings.ToLower(method.String())
+	}
+
+	return id
+}
+
+// envelope 生成业务应答的外层结构。
+func (doc *openAPIDocument) envelope(data reflect.Type) *openAPISchema {
+	codes, descriptions := registeredErrCodes()
+	enum := make([]interface{}, 0, len(codes))
+	desc := make([]string, 0, len(codes))
+
+	for i, code := range codes {
+		enum = append(enum, code)
+		desc = append(desc, fmt.Sprintf("%v: %v", code, descriptions[i]))
+	}
+
+	return &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"err": {
+				Type:        "integer",
+				Description: strings.Join(desc, "; "),
+				Enum:        enum,
+			},
+			"msg": {
+				Type:        "string",
+				Description: "error message, only presents when err is not 0",
+			},
+			"now": {
+				Type:   "string",
+				Format: "date-time",
+			},
+			"data": doc.schema(data),
+		},
+	}
+}
+
+// schema 根据 Go 类型生成 JSON schema，有名字的结构体会放在 components 里面并通过 $ref 引用。
+func (doc *openAPIDocument) schema(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case typeOfTime:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case typeOfBytes:
+		return &openAPISchema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Struct:
+		return doc.structSchema(t)
+	}
+
+	return &openAPISchema{}
+}
+
+func (doc *openAPIDocument) structSchema(t reflect.Type) *openAPISchema {
+	if t.Name() == "" {
+		return doc.objectSchema(t)
+	}
+
+	if name, ok := doc.schemas[t]; ok {
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	}
+
+	// 不同包里面可能有同名的类型，需要加上包名区分。
+	name := t.Name()
+
+	if _, ok := doc.names[name]; ok {
+		name = strings.Replace(t.String(), ".", "_", -1)
+	}
+
+	// 先占位再生成，避免递归类型导致死循环。
+	doc.schemas[t] = name
+	doc.names[name] = t
+	doc.Components.Schemas[name] = doc.objectSchema(t)
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func (doc *openAPIDocument) objectSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{
+		Type:       "object",
+		Properties: map[string]*openAPISchema{},
+	}
+
+	for _, field := range structFields(t) {
+		if name := tagName(field, "json"); name != "" {
+			s.Properties[name] = doc.schema(field.Type)
+		}
+	}
+
+	return s
+}
+
+// structFields 返回 t 的所有导出字段，匿名嵌入的结构体会被展开。
+func structFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous {
+			ft := field.Type
+
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+				fields = append(fields, structFields(ft)...)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// tagName 返回字段在 tag 中的名字，如果字段被忽略则返回空字符串。
+func tagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+
+	if name == "-" {
+		return ""
+	}
+
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func testOpenAPIRoutes() Routes {
+	return RouteMap{
+		"/passport": RouteList{
+			R("login", POST, testLogin),
+			R("validate", ANY, testValidate),
+		},
+		"/project": RouteList{
+			R("list", POST, testProjectList),
+			R("raw/:id", GET, validBizFunc7),
+		},
+	}
+}
+
+func TestListRoutes(t *testing.T) {
+	a := assert.New(t)
+	routes, err := ListRoutes(testOpenAPIRoutes())
+	a.NilError(err)
+	a.Equal(len(routes), 4)
+
+	paths := map[string]*RouteInfo{}
+
+	for _, r := range routes {
+		paths[r.Path] = r
+	}
+
+	a.Equal(paths["/passport/login"].Method, POST)
+	a.Equal(paths["/passport/login"].Request.Name(), "testLoginRequest")
+	a.Equal(paths["/project/list"].Response.Name(), "testCommonResponse")
+	a.Equal(paths["/project/raw/:id"].Request, nil)
+
+	_, err = ListRoutes(RouteList{
+		R("bad", POST, invalidBizFunc1),
+	})
+	a.Assert(err != nil)
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	a := assert.New(t)
+	data, err := GenerateOpenAPI(testOpenAPIRoutes(), &OpenAPIInfo{
+		Title:   "test",
+		Version: "1.0",
+	})
+	a.NilError(err)
+
+	var doc struct {
+		OpenAPI string                                  `json:"openapi"`
+		Paths   map[string]map[string]*openAPIOperation `json:"paths"`
+		Schemas struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+		ErrCodes map[string]string `json:"x-error-codes"`
+	}
+	a.NilError(json.Unmarshal(data, &doc))
+	a.Equal(doc.OpenAPI, openAPIVersion)
+
+	login := doc.Paths["/passport/login"]["post"]
+	a.Equal(login.OperationID, "server.testLogin")
+	a.Equal(len(login.Parameters), 0)
+	body := login.RequestBody.Content["application/json"].Schema
+	a.Equal(body.Properties["username"].Type, "string")
+	a.Equal(body.Properties["passport"].Type, "string")
+	envelope := login.Responses["200"].Content["application/json"].Schema
+	a.Equal(envelope.Properties["err"].Type, "integer")
+	a.Equal(envelope.Properties["data"].Ref, "#/components/schemas/testCommonResponse")
+	a.Equal(doc.Schemas.Schemas["testCommonResponse"].Properties["bar"].Format, "int64")
+
+	list := doc.Paths["/project/list"]["post"]
+	a.Equal(len(list.Parameters), 1)
+	a.Equal(list.Parameters[0].Name, "uid")
+	a.Equal(list.Parameters[0].In, "query")
+	body = list.RequestBody.Content["application/json"].Schema
+	a.Equal(len(body.Properties), 2)
+
+	// ANY 路由展开成多个方法，GET 请求的参数全部来自 query。
+	validate := doc.Paths["/passport/validate"]
+	a.Equal(len(validate), len(openAPIAnyMethods))
+	a.Equal(validate["get"].OperationID, "server.testValidate_get")
+	a.Equal(len(validate["get"].Parameters), 2)
+	a.Equal(validate["get"].RequestBody, nil)
+
+	raw := doc.Paths["/project/raw/{id}"]["get"]
+	a.Equal(raw.Parameters[0].Name, "id")
+	a.Equal(raw.Parameters[0].In, "path")
+
+	a.Equal(doc.ErrCodes["1"], "上游请求参数不合法")
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		OpenAPIURI: "openapi.json",
+	})
+	a.NilError(s.AddRoutes(testOpenAPIRoutes()))
+	testServer := httptest.NewServer(s.Handler())
+	defer testServer.Close()
+
+	resp, err := http.Get(testServer.URL + "/openapi.json")
+	a.NilError(err)
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusOK)
+
+	var doc map[string]interface{}
+	a.NilError(json.Unmarshal(data, &doc))
+	a.Equal(len(doc["paths"].(map[string]interface{})), 4)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+// PanicHook 是处理请求时发生 panic 的回调函数，一般用来向崩溃收集服务上报错误。
+// recovered 是 recover() 的返回值，stack 是发生 panic 时的调用栈。
+type PanicHook func(ctx context.Context, recovered interface{}, stack []byte)
+
+// OnPanic 注册一个 PanicHook，处理请求时发生 panic 会按照注册顺序调用所有 PanicHook。
+// 这个函数应该在 Serve 之前调用，一般放在 OnStart 回调里。
+func (s *Server) OnPanic(hook PanicHook) {
+	if hook == nil {
+		return
+	}
+
+	s.panicHooks = append(s.panicHooks, hook)
+}
+
+// recoverPanic 是所有请求都会执行的中间件，处理没有被处理函数捕获的 panic，例如中间件里发生的 panic。
+func (s *Server) recoverPanic(c *httpContext) {
+	defer func() {
+		if r := recover(); r != nil {
+			// 处理函数已经处理过这个 panic，说明设置了 Repanic，直接继续 panic。
+			if c.panicked {
+				panic(r)
+			}
+
+			ctx := c.Request.Context()
+
+			if _, ok := ctx.Value(keyStartTime).(time.Time); !ok {
+				ctx = context.WithValue(ctx, keyStartTime, time.Now())
+			}
+
+			handlePanic(ctx, c, r)
+		}
+	}()
+
+	c.Next()
+}
+
+// handlePanic 处理请求时捕获的 panic，记录日志和监控、调用所有 PanicHook 并输出应答。
+//
+// 只有在调试模式下应答才会包含 panic 的详细信息，避免向客户端泄露服务内部的信息。
+// 如果设置了 Repanic，处理完之后会重新 panic，一般用于在测试中发现问题。
+func handlePanic(ctx context.Context, c *httpContext, recovered interface{}) {
+	c.panicked = true
+	stack := debug.Stack()
+	route := c.FullPath()
+
+	if route == "" {
+		route = noRouteMetricsTag
+	}
+
+	serverMetrics.Panic.Add(1)
+	httpMetrics.Panic.AddForTag(route, 1)
+	log.Errorf(ctx, "err=%v||url=%v||method=%v||route=%v||go-http: caught a panic with call stack\n%v", recovered, c.Request.URL, c.Request.Method, route, string(stack))
+
+	s := serverFrom(c)
+	em := newErrorMsg(ErrCodeServerPanic, "go-http: internal server error")
+
+	if s != nil {
+		for _, hook := range s.panicHooks {
+			callPanicHook(ctx, hook, recovered, stack)
+		}
+
+		if s.config.Debug {
+			em = newErrorMsg(ErrCodeServerPanic, fmt.Sprintf("go-http: caught a panic [err:%v]", recovered))
+		}
+	}
+
+	// 如果已经输出了部分应答，无法再输出错误信息，只记录日志和监控。
+	if c.Writer.Written() {
+		reportResponse(ctx, c, ErrCodeServerPanic)
+	} else {
+		writeEnvelope(ctx, c, http.StatusInternalServerError, em, nil)
+	}
+
+	if s != nil && s.config.Repanic {
+		panic(recovered)
+	}
+}
+
+// callPanicHook 调用 hook，hook 自己发生 panic 时只记录日志，避免影响其他 hook 和应答。
+func callPanicHook(ctx context.Context, hook PanicHook, recovered interface{}, stack []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf(ctx, "err=%v||go-http: panic hook panics", r)
+		}
+	}()
+
+	hook(ctx, recovered, stack)
+}
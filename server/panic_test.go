@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func testPanicHandler(ctx context.Context, req *struct{}) (*struct{}, error) {
+	panic("secret detail")
+}
+
+func TestPanic(t *testing.T) {
+	a := assert.New(t)
+
+	for _, engine := range testEngines {
+		for _, debug := range []bool{false, true} {
+			a.Use(engine, debug)
+			s := New(&Config{
+				Engine: engine,
+				Debug:  debug,
+			})
+			a.NilError(s.AddRoutes(RouteList{
+				R("/panic", GET, testPanicHandler),
+			}))
+			s.engine.Handle(http.MethodGet, "/raw", func(c *httpContext) {
+				panic("raw detail")
+			})
+
+			var recovered []interface{}
+			var stacks [][]byte
+			s.OnPanic(func(ctx context.Context, r interface{}, stack []byte) {
+				recovered = append(recovered, r)
+				stacks = append(stacks, stack)
+			})
+			s.OnPanic(func(ctx context.Context, r interface{}, stack []byte) {
+				panic("hook should not break other hooks")
+			})
+
+			call := func(uri string) map[string]interface{} {
+				w := httptest.NewRecorder()
+				s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, uri, nil))
+				a.Equal(w.Code, http.StatusInternalServerError)
+
+				res := map[string]interface{}{}
+				a.NilError(json.Unmarshal(w.Body.Bytes(), &res))
+				a.Equal(res["err"], float64(ErrCodeServerPanic))
+				return res
+			}
+
+			// 业务函数和没有经过包装的处理函数里的 panic 都会被捕获。
+			res := call("/panic")
+			raw := call("/raw")
+
+			if debug {
+				a.Equal(res["msg"], newErrorMsg(ErrCodeServerPanic, "go-http: caught a panic [err:secret detail]").Error())
+				a.Equal(raw["msg"], newErrorMsg(ErrCodeServerPanic, "go-http: caught a panic [err:raw detail]").Error())
+			} else {
+				a.Equal(res["msg"], newErrorMsg(ErrCodeServerPanic, "go-http: internal server error").Error())
+				a.Equal(raw["msg"], newErrorMsg(ErrCodeServerPanic, "go-http: internal server error").Error())
+			}
+
+			a.Equal(recovered, []interface{}{"secret detail", "raw detail"})
+			a.Equal(len(stacks), 2)
+			a.Assert(len(stacks[0]) > 0)
+		}
+	}
+}
+
+func TestRepanic(t *testing.T) {
+	a := assert.New(t)
+
+	for _, engine := range testEngines {
+		a.Use(engine)
+		s := New(&Config{
+			Engine:  engine,
+			Repanic: true,
+		})
+		a.NilError(s.AddRoutes(RouteList{
+			R("/panic", GET, testPanicHandler),
+		}))
+
+		hooks := 0
+		s.OnPanic(func(ctx context.Context, r interface{}, stack []byte) {
+			hooks++
+		})
+
+		w := httptest.NewRecorder()
+		var recovered interface{}
+		func() {
+			defer func() {
+				recovered = recover()
+			}()
+
+			s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		}()
+
+		// 重新 panic 之前已经输出了应答，并且只处理一次。
+		a.Equal(recovered, "secret detail")
+		a.Equal(w.Code, http.StatusInternalServerError)
+		a.Equal(hooks, 1)
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type keyRequestType struct{}
+
+var keyRequest keyRequestType
+
+// trustUnixSocket 是 TrustedProxies 中代表信任所有 Unix socket 连接的特殊值。
+const trustUnixSocket = "unix"
+
+// requestInfo 记录当前请求的原始信息。
+type requestInfo struct {
+	request *http.Request
+	route   string
+	params  routeParams
+	proxies *trustedProxies
+}
+
+func withRequest(ctx context.Context, c *httpContext) context.Context {
+	info := &requestInfo{
+		request: c.Request,
+		route:   c.FullPath(),
+		params:  c.params,
+	}
+
+	if s := serverFrom(c); s != nil {
+		info.proxies = s.trustedProxies
+	}
+
+	return context.WithValue(ctx, keyRequest, info)
+}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(keyRequest).(*requestInfo)
+	return info
+}
+
+// RequestFrom 返回当前请求的 *http.Request，如果 ctx 不是框架传给业务函数的 ctx 则返回 nil。
+// 业务函数不应该再读取请求的 body，框架在解析参数的时候已经读过了。
+func RequestFrom(ctx context.Context) *http.Request {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.request
+	}
+
+	return nil
+}
+
+// RouteFrom 返回当前请求匹配到的路由，例如 "/user/:id"，如果 ctx 不是框架传给业务函数的 ctx 则返回空字符串。
+func RouteFrom(ctx context.Context) string {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.route
+	}
+
+	return ""
+}
+
+// Param 返回路由中名为 name 的参数，例如路由是 "/user/:id" 时，Param(ctx, "id") 返回 id 的值。
+func Param(ctx context.Context, name string) string {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.params.byName(name)
+	}
+
+	return ""
+}
+
+// Params 返回路由中所有的参数。
+func Params(ctx context.Context) map[string]string {
+	info := requestInfoFromContext(ctx)
+
+	if info == nil {
+		return nil
+	}
+
+	params := make(map[string]string, len(info.params))
+
+	for _, p := range info.params {
+		params[p.key] = p.value
+	}
+
+	return params
+}
+
+// StartTime 返回框架开始处理当前请求的时间，如果 ctx 不是框架传给业务函数的 ctx 则返回零值。
+func StartTime(ctx context.Context) time.Time {
+	start, _ := ctx.Value(keyStartTime).(time.Time)
+	return start
+}
+
+// ClientIP 返回客户端的 IP 地址，如果 ctx 不是框架传给业务函数的 ctx 则返回空字符串。
+//
+// 只有直接连接的对端地址属于 Config.TrustedProxies 时，才会使用 X-Forwarded-For 和 X-Real-Ip header：
+//     - 从右往左查找 X-Forwarded-For 中第一个不属于 TrustedProxies 的地址；
+//     - 遇到非法地址时停止查找，使用最后一个可信的地址；
+//     - 如果没有 X-Forwarded-For，使用 X-Real-Ip；
+//     - 否则使用对端地址。
+func ClientIP(ctx context.Context) string {
+	info := requestInfoFromContext(ctx)
+
+	if info == nil {
+		return ""
+	}
+
+	return info.proxies.clientIP(info.request)
+}
+
+// trustedProxies 是解析后的 Config.TrustedProxies。
+type trustedProxies struct {
+	nets []*net.IPNet
+	unix bool
+}
+
+// parseTrustedProxies 解析 proxies，每一项可以是 IP、CIDR 或者 trustUnixSocket。
+func parseTrustedProxies(proxies []string) (*trustedProxies, error) {
+	tp := &trustedProxies{}
+
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+
+		if proxy == trustUnixSocket {
+			tp.unix = true
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+
+			if ip == nil {
+				return nil, fmt.Errorf("go-http: invalid trusted proxy [proxy:%v]", proxy)
+			}
+
+			bits := 8 * net.IPv6len
+
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+				bits = 8 * net.IPv4len
+			}
+
+			tp.nets = append(tp.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(proxy)
+
+		if err != nil {
+			return nil, fmt.Errorf("go-http: invalid trusted proxy [proxy:%v] [err:%v]", proxy, err)
+		}
+
+		tp.nets = append(tp.nets, ipnet)
+	}
+
+	return tp, nil
+}
+
+func (tp *trustedProxies) trusted(ip net.IP) bool {
+	if tp == nil || ip == nil {
+		return false
+	}
+
+	for _, ipnet := range tp.nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (tp *trustedProxies) clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	remoteIP := net.ParseIP(remote)
+
+	// Unix socket 的对端地址不是 IP。
+	if remoteIP == nil {
+		if tp == nil || !tp.unix {
+			return remote
+		}
+	} else if !tp.trusted(remoteIP) {
+		return remote
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) != 0 {
+		addrs := strings.Split(strings.Join(xff, ","), ",")
+
+		// last 是最后一个确认可信的地址，一开始是直接连接的对端。
+		last := remote
+
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			ip := net.ParseIP(addr)
+
+			// 非法地址左边的内容都不可信，使用最后一个可信的地址。
+			if ip == nil {
+				return last
+			}
+
+			if !tp.trusted(ip) {
+				return addr
+			}
+
+			last = addr
+		}
+
+		// 所有地址都是可信代理，使用最左边的地址。
+		return last
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return remote
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testRequestInfoRequest struct{}
+
+type testRequestInfoResponse struct {
+	Method   string            `json:"method"`
+	Route    string            `json:"route"`
+	Params   map[string]string `json:"params"`
+	ID       string            `json:"id"`
+	ClientIP string            `json:"client_ip"`
+	Started  bool              `json:"started"`
+}
+
+func testRequestInfo(ctx context.Context, req *testRequestInfoRequest) (*testRequestInfoResponse, error) {
+	start := StartTime(ctx)
+
+	return &testRequestInfoResponse{
+		Method:   RequestFrom(ctx).Method,
+		Route:    RouteFrom(ctx),
+		Params:   Params(ctx),
+		ID:       Param(ctx, "id"),
+		ClientIP: ClientIP(ctx),
+		Started:  !start.IsZero() && !start.After(time.Now()),
+	}, nil
+}
+
+func TestRequestInfo(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/user": RouteList{
+			R(":id/item/:item", GET, testRequestInfo),
+		},
+	}))
+
+	call := func(remoteAddr string, headers map[string]string) *testRequestInfoResponse {
+		r := httptest.NewRequest(http.MethodGet, "/user/42/item/abc", nil)
+		r.RemoteAddr = remoteAddr
+
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		a.Equal(w.Code, http.StatusOK)
+
+		var body struct {
+			Data *testRequestInfoResponse `json:"data"`
+		}
+		a.NilError(json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data
+	}
+
+	res := call("1.2.3.4:1234", nil)
+	a.Equal(res.Method, http.MethodGet)
+	a.Equal(res.Route, "/user/:id/item/:item")
+	a.Equal(res.Params, map[string]string{"id": "42", "item": "abc"})
+	a.Equal(res.ID, "42")
+	a.Equal(res.ClientIP, "1.2.3.4")
+	a.Assert(res.Started)
+
+	// 不可信的对端不能伪造客户端地址。
+	res = call("1.2.3.4:1234", map[string]string{"X-Forwarded-For": "5.6.7.8"})
+	a.Equal(res.ClientIP, "1.2.3.4")
+
+	// 可信代理传入的地址从右往左跳过所有可信代理。
+	res = call("10.1.2.3:1234", map[string]string{"X-Forwarded-For": "9.9.9.9, 5.6.7.8, 192.168.1.1, 10.0.0.1"})
+	a.Equal(res.ClientIP, "5.6.7.8")
+
+	res = call("192.168.1.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.2, 10.0.0.1"})
+	a.Equal(res.ClientIP, "10.0.0.2")
+
+	// 非法地址左边的内容不可信，使用最后一个可信的地址。
+	res = call("10.1.2.3:1234", map[string]string{"X-Forwarded-For": "5.6.7.8, bad, 10.0.0.1"})
+	a.Equal(res.ClientIP, "10.0.0.1")
+
+	res = call("10.1.2.3:1234", map[string]string{"X-Forwarded-For": "5.6.7.8, bad"})
+	a.Equal(res.ClientIP, "10.1.2.3")
+
+	res = call("10.1.2.3:1234", map[string]string{"X-Real-Ip": "5.6.7.8"})
+	a.Equal(res.ClientIP, "5.6.7.8")
+
+	res = call("192.168.1.2:1234", map[string]string{"X-Real-Ip": "5.6.7.8"})
+	a.Equal(res.ClientIP, "192.168.1.2")
+}
+
+func TestRequestInfoWithoutServer(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	a.Equal(RequestFrom(ctx), (*http.Request)(nil))
+	a.Equal(RouteFrom(ctx), "")
+	a.Equal(Param(ctx, "id"), "")
+	a.Equal(Params(ctx), map[string]string(nil))
+	a.Equal(ClientIP(ctx), "")
+	a.Assert(StartTime(ctx).IsZero())
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	a := assert.New(t)
+
+	tp, err := parseTrustedProxies([]string{"unix", "::1", "172.16.0.0/12"})
+	a.NilError(err)
+	a.Assert(tp.unix)
+	a.Equal(len(tp.nets), 2)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "@"
+	r.Header.Set("X-Forwarded-For", "5.6.7.8, 172.16.3.4")
+	a.Equal(tp.clientIP(r), "5.6.7.8")
+
+	r.RemoteAddr = "[::1]:1234"
+	a.Equal(tp.clientIP(r), "5.6.7.8")
+
+	_, err = parseTrustedProxies([]string{"not-an-ip"})
+	a.NonNilError(err)
+
+	_, err = parseTrustedProxies([]string{"10.0.0.0/33"})
+	a.NonNilError(err)
+
+	// 配置错误时服务不能启动。
+	s := New(&Config{
+		TrustedProxies: []string{"not-an-ip"},
+	})
+	a.NonNilError(s.Serve())
+}
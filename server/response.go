@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type keyResponseType struct{}
+
+var keyResponse keyResponseType
+
+// response 记录业务代码通过 ctx 设置的应答信息，框架输出应答时会使用这些信息。
+type response struct {
+	mu      sync.Mutex
+	status  int
+	header  http.Header
+	cookies []*http.Cookie
+}
+
+func withResponse(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyResponse, &response{
+		header: http.Header{},
+	})
+}
+
+func responseFromContext(ctx context.Context) *response {
+	res, _ := ctx.Value(keyResponse).(*response)
+	return res
+}
+
+// SetHeader 设置应答的 HTTP header，会覆盖同名 header 之前的值。
+// 如果 ctx 不是框架传给业务函数的 ctx，这个函数什么都不做。
+func SetHeader(ctx context.Context, key, value string) {
+	if res := responseFromContext(ctx); res != nil {
+		res.mu.Lock()
+		defer res.mu.Unlock()
+		res.header.Set(key, value)
+	}
+}
+
+// AddHeader 为应答增加一个 HTTP header，不会覆盖同名 header 之前的值。
+// 如果 ctx 不是框架传给业务函数的 ctx，这个函数什么都不做。
+func AddHeader(ctx context.Context, key, value string) {
+	if res := responseFromContext(ctx); res != nil {
+		res.mu.Lock()
+		defer res.mu.Unlock()
+		res.header.Add(key, value)
+	}
+}
+
+// SetCookie 为应答增加一个 Set-Cookie header。
+// 如果 ctx 不是框架传给业务函数的 ctx，这个函数什么都不做。
+func SetCookie(ctx context.Context, cookie *http.Cookie) {
+	if cookie == nil {
+		return
+	}
+
+	if res := responseFromContext(ctx); res != nil {
+		res.mu.Lock()
+		defer res.mu.Unlock()
+		res.cookies = append(res.cookies, cookie)
+	}
+}
+
+// SetStatus 设置应答的 HTTP 状态码，例如 http.StatusCreated 或 http.StatusNoContent。
+// 状态码只在业务函数没有返回错误时生效，应答依然是 `{"err":0,"msg":"","data":{}}` 格式，
+// 如果状态码不允许带 body（例如 204），框架不会输出 body。
+// 如果 ctx 不是框架传给业务函数的 ctx，这个函数什么都不做。
+func SetStatus(ctx context.Context, status int) {
+	if res := responseFromContext(ctx); res != nil {
+		res.mu.Lock()
+		defer res.mu.Unlock()
+		res.status = status
+	}
+}
+
+// Redirect 设置 Location header 和 3xx 状态码，让客户端跳转到 location。
+// 与 SetStatus 一样，状态码只在业务函数没有返回错误时生效。
+func Redirect(ctx context.Context, status int, location string) {
+	SetHeader(ctx, "Location", location)
+	SetStatus(ctx, status)
+}
+
+// applyResponse 将业务代码设置的 header 和 cookie 写入应答，并返回最终的状态码。
+// 只有 status 是 200 并且 code 是 ErrCodeOK 时，业务代码设置的状态码才会生效。
+func applyResponse(ctx context.Context, c *httpContext, status int, code interface{}) int {
+	res := responseFromContext(ctx)
+
+	if res == nil {
+		return status
+	}
+
+	res.mu.Lock()
+	defer res.mu.Unlock()
+
+	header := c.Writer.Header()
+
+	for k, v := range res.header {
+		header[k] = append([]string(nil), v...)
+	}
+
+	for _, cookie := range res.cookies {
+		http.SetCookie(c.Writer, cookie)
+	}
+
+	if res.status != 0 && status == http.StatusOK {
+		if c, ok := code.(int); ok && c == ErrCodeOK {
+			return res.status
+		}
+	}
+
+	return status
+}
+
+// extendWriteDeadline 将连接的写超时设置为从现在开始的 timeout，timeout 为 0 表示不限制。
+//
+// WriteTimeout 是从读完请求开始计算的，流式应答和 SSE 持续的时间往往更长，
+// 每次输出之前都需要延长写超时，否则连接会在 WriteTimeout 之后被直接断开。
+func extendWriteDeadline(w http.ResponseWriter, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+}
+
+// writeTimeout 返回处理 c 的 Server 设置的 WriteTimeout。
+func writeTimeout(c *httpContext) time.Duration {
+	if s := serverFrom(c); s != nil {
+		return s.config.WriteTimeout
+	}
+
+	return 0
+}
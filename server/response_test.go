@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testResponseRequest struct {
+	Action string `form:"action"`
+}
+
+func testResponseControl(ctx context.Context, req *testResponseRequest) (*testCommonResponse, error) {
+	SetHeader(ctx, "X-Foo", "foo")
+	AddHeader(ctx, "X-Bar", "1")
+	AddHeader(ctx, "X-Bar", "2")
+	SetCookie(ctx, &http.Cookie{Name: "session", Value: "abc"})
+
+	switch req.Action {
+	case "create":
+		SetStatus(ctx, http.StatusCreated)
+	case "delete":
+		SetStatus(ctx, http.StatusNoContent)
+		return nil, nil
+	case "redirect":
+		Redirect(ctx, http.StatusFound, "/somewhere")
+	case "fail":
+		SetStatus(ctx, http.StatusCreated)
+		return nil, Error(1001, "failed")
+	}
+
+	return newTestCommonResponse(), nil
+}
+
+func TestResponseControl(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/": RouteList{
+			R("response", GET, testResponseControl),
+		},
+	}))
+
+	call := func(action string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/response?action="+action, nil)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		return w
+	}
+
+	w := call("create")
+	a.Equal(w.Code, http.StatusCreated)
+	a.Equal(w.Header().Get("X-Foo"), "foo")
+	a.Equal(w.Header()["X-Bar"], []string{"1", "2"})
+	a.Equal(w.Header().Get("Set-Cookie"), "session=abc")
+	validateResponse(a, w.Result())
+
+	w = call("delete")
+	a.Equal(w.Code, http.StatusNoContent)
+	a.Equal(w.Body.Len(), 0)
+
+	w = call("redirect")
+	a.Equal(w.Code, http.StatusFound)
+	a.Equal(w.Header().Get("Location"), "/somewhere")
+
+	// 业务函数返回错误时，状态码不会生效，但 header 依然会输出。
+	w = call("fail")
+	a.Equal(w.Code, http.StatusOK)
+	a.Equal(w.Header().Get("X-Foo"), "foo")
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteInfo 是一条已经注册的路由的详细信息。
+type RouteInfo struct {
+	Method      Method       // Method 是路由的 HTTP 请求方法。
+	Path        string       // Path 是路由的完整路径。
+	Handler     string       // Handler 是处理函数的名字。
+	Request     reflect.Type // Request 是业务函数的请求类型，如果不是业务函数则为 nil。
+	Response    reflect.Type // Response 是业务函数的应答类型，如果不是业务函数或者是流式输出则为 nil。
+	Middlewares []string     // Middlewares 是在处理函数之前执行的所有函数的名字。
+	Deprecated  bool         // Deprecated 表示路由已经废弃，详见 Route#Deprecate。
+	Sunset      time.Time    // Sunset 是废弃路由计划下线的时间，零值表示没有设置。
+}
+
+type routeRegistry struct {
+	mu     sync.Mutex
+	routes []*RouteInfo
+}
+
+func (rr *routeRegistry) add(info *RouteInfo) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.routes = append(rr.routes, info)
+}
+
+func (rr *routeRegistry) list() []*RouteInfo {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	routes := make([]*RouteInfo, len(rr.routes))
+	copy(routes, rr.routes)
+	return routes
+}
+
+// newRouteInfo 根据路由配置生成路由信息，handlers 中最后一个函数是处理函数，其他都是中间件。
+func newRouteInfo(method Method, fullPath string, middlewares []string, handlers []Handler) *RouteInfo {
+	info := &RouteInfo{
+		Method: method,
+		Path:   fullPath,
+	}
+
+	if len(handlers) == 0 {
+		info.Middlewares = middlewares
+		return info
+	}
+
+	last := len(handlers) - 1
+	info.Middlewares = append(info.Middlewares, middlewares...)
+
+	for _, h := range handlers[:last] {
+		if d, ok := h.(*deprecation); ok {
+			info.Deprecated = true
+			info.Sunset = d.sunset
+			continue
+		}
+
+		info.Middlewares = append(info.Middlewares, handlerName(h))
+	}
+
+	info.Handler = handlerName(handlers[last])
+	info.Request, info.Response = businessTypes(handlers[last])
+	return info
+}
+
+func handlerNames(handlers []Handler) []string {
+	names := make([]string, 0, len(handlers))
+
+	for _, h := range handlers {
+		names = append(names, handlerName(h))
+	}
+
+	return names
+}
+
+func handlerName(handler Handler) string {
+	if handler == nil {
+		return "<nil>"
+	}
+
+	if h, ok := handler.(*wsHandler); ok {
+		return handlerName(h.onMessage)
+	}
+
+	v := reflect.ValueOf(handler)
+
+	if v.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(v.Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+
+	return fmt.Sprintf("%T", handler)
+}
+
+// businessTypes 返回业务函数的请求和应答类型，如果 handler 不是业务函数则返回 nil。
+func businessTypes(handler Handler) (req, res reflect.Type) {
+	if _, ok := handler.(http.Handler); ok {
+		return
+	}
+
+	t := reflect.TypeOf(handler)
+
+	if t == nil || t.Kind() != reflect.Func || t.ConvertibleTo(typeOfHTTPHandlerFunc) || t.NumIn() != 2 || t.NumOut() != 2 {
+		return
+	}
+
+	req = t.In(1)
+	res = t.Out(0)
+
+	if req.Kind() == reflect.Ptr {
+		req = req.Elem()
+	}
+
+	if res.Kind() == reflect.Ptr {
+		res = res.Elem()
+	}
+
+	// 流式输出的业务函数没有固定的应答结构。
+	if res.Kind() != reflect.Struct {
+		res = nil
+	}
+
+	return
+}
+
+// JoinPaths 拼接路由路径，与 gin 的规则保持一致，保留 relativePath 结尾的“/”。
+// 注册路由时 Router 使用同样的规则拼接 SubRouter 的前缀和路由路径。
+func JoinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	finalPath := path.Join(absolutePath, relativePath)
+
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+
+	return finalPath
+}
+
+// ListRoutes 分析 routes 里面的所有路由，返回路由的详细信息。
+// 与 Server#AddRoutes 一样，如果 routes 里面有不合法的处理函数或者冲突的路由会返回错误。
+func ListRoutes(routes Routes) ([]*RouteInfo, error) {
+	table := &routeTable{}
+
+	if err := routes.Register(newTableRouter(table)); err != nil {
+		return nil, err
+	}
+
+	if err := table.check(nil); err != nil {
+		return nil, err
+	}
+
+	return table.infos(), nil
+}
@@ -1,7 +1,7 @@
 package server
 
 import (
-	"github.com/gin-gonic/gin"
+	"fmt"
 )
 
 // Router 代表一个路由器实现，Routes 可以向 Router 注册路由信息。
@@ -11,45 +11,63 @@ type Router interface {
 	HandleAny(uri string, handlers ...Handler) error
 }
 
-type ginRouter struct {
-	router *gin.RouterGroup
+// tableRouter 将路由记录到 routeTable 里，所有路由检查通过之后才会真正注册到路由引擎。
+type tableRouter struct {
+	table       *routeTable
+	prefix      string
+	handlers    []handlerFunc
+	middlewares []string
 }
 
-func newGinRouter(router *gin.RouterGroup) *ginRouter {
-	return &ginRouter{
-		router: router,
+func newTableRouter(table *routeTable) *tableRouter {
+	return &tableRouter{
+		table:  table,
+		prefix: "/",
 	}
 }
 
-func (gr *ginRouter) SubRouter(uri string, handlers ...Handler) (Router, error) {
-	hfs, err := parseHandlersForGin(handlers)
+func (tr *tableRouter) SubRouter(uri string, handlers ...Handler) (Router, error) {
+	prefix := JoinPaths(tr.prefix, uri)
+	hfs, err := parseHandlers(handlers)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("go-http: invalid middleware [path:%v] [err:%v]", prefix, err)
 	}
 
-	router := gr.router.Group(uri, hfs...)
-	return newGinRouter(router), nil
+	sub := &tableRouter{
+		table:  tr.table,
+		prefix: prefix,
+	}
+	sub.handlers = append(sub.handlers, tr.handlers...)
+	sub.handlers = append(sub.handlers, hfs...)
+	sub.middlewares = append(sub.middlewares, tr.middlewares...)
+	sub.middlewares = append(sub.middlewares, handlerNames(handlers)...)
+	return sub, nil
 }
 
-func (gr *ginRouter) Handle(method Method, uri string, handlers ...Handler) error {
-	hfs, err := parseHandlersForGin(handlers)
+func (tr *tableRouter) Handle(method Method, uri string, handlers ...Handler) error {
+	fullPath := JoinPaths(tr.prefix, uri)
+	hfs, err := parseHandlers(handlers)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("go-http: invalid route [method:%v] [path:%v] [err:%v]", method, fullPath, err)
 	}
 
-	gr.router.Handle(method.String(), uri, hfs...)
-	return nil
-}
-
-func (gr *ginRouter) HandleAny(uri string, handlers ...Handler) error {
-	hfs, err := parseHandlersForGin(handlers)
-
-	if err != nil {
-		return err
+	if err := validateRoutePath(fullPath); err != nil {
+		return fmt.Errorf("go-http: invalid route [method:%v] [path:%v] [err:%v]", method, fullPath, err)
 	}
 
-	gr.router.Any(uri, hfs...)
+	chain := make([]handlerFunc, 0, len(tr.handlers)+len(hfs))
+	chain = append(chain, tr.handlers...)
+	chain = append(chain, hfs...)
+
+	tr.table.add(&routeEntry{
+		info:     newRouteInfo(method, fullPath, tr.middlewares, handlers),
+		handlers: chain,
+	})
 	return nil
 }
+
+func (tr *tableRouter) HandleAny(uri string, handlers ...Handler) error {
+	return tr.Handle(ANY, uri, handlers...)
+}
@@ -1,5 +1,9 @@
 package server
 
+import (
+	"sort"
+)
+
 // Routes 是一个抽象的路由配置表。
 type Routes interface {
 	Register(router Router) error
@@ -24,16 +28,26 @@ func R(uri string, method Method, handlers ...Handler) *Route {
 // RouteMap 是路由配置表。
 type RouteMap map[string]Routes
 
-// Register 将 rm 的路由配置注册到 router 里面去。
+// Register 将 rm 的路由配置注册到 router 里面去，按照 uri 的字典序注册，保证每次注册的顺序一致。
 func (rm RouteMap) Register(router Router) error {
-	for uri, routes := range rm {
+	uris := make([]string, 0, len(rm))
+
+	for uri := range rm {
+		uris = append(uris, uri)
+	}
+
+	sort.Strings(uris)
+
+	for _, uri := range uris {
 		sub, err := router.SubRouter(uri)
 
 		if err != nil {
 			return err
 		}
 
-		routes.Register(sub)
+		if err := rm[uri].Register(sub); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// routeEntry 是路由表中的一条路由。
+type routeEntry struct {
+	info     *RouteInfo
+	handlers []handlerFunc
+}
+
+// routeTable 是 Routes 生成的完整路由表。
+type routeTable struct {
+	entries []*routeEntry
+}
+
+func (rt *routeTable) add(entry *routeEntry) {
+	rt.entries = append(rt.entries, entry)
+}
+
+func (rt *routeTable) infos() []*RouteInfo {
+	infos := make([]*RouteInfo, 0, len(rt.entries))
+
+	for _, entry := range rt.entries {
+		infos = append(infos, entry.info)
+	}
+
+	return infos
+}
+
+// check 检查路由表中的路由之间、以及与 existing 之间是否存在冲突。
+//
+// 为了保证切换路由引擎时路由表依然可用，冲突规则取所有路由引擎中最严格的规则：
+//     - 方法和路径都相同的路由是重复路由，ANY 与所有方法都重复；
+//     - 同一个位置上的路径参数必须同名，例如 /user/:id 和 /user/:uid/profile 冲突；
+//     - 同一个位置上不能同时存在路径参数和固定路径，例如 /user/:id 和 /user/list 冲突；
+//     - 通配参数所在的位置不能有任何其他路由，例如 /static/*path 和 /static/index.html 冲突。
+func (rt *routeTable) check(existing []*RouteInfo) error {
+	checked := make([]*RouteInfo, 0, len(existing)+len(rt.entries))
+	checked = append(checked, existing...)
+
+	for _, entry := range rt.entries {
+		info := entry.info
+
+		for _, other := range checked {
+			if !methodsOverlap(info.Method, other.Method) {
+				continue
+			}
+
+			switch routePathsConflict(info.Path, other.Path) {
+			case routeDuplicate:
+				return fmt.Errorf("go-http: duplicate route [method:%v] [path:%v] [existing:%v %v]", info.Method, info.Path, other.Method, other.Path)
+			case routeConflict:
+				return fmt.Errorf("go-http: route conflicts with an existing route [method:%v] [path:%v] [existing:%v %v]", info.Method, info.Path, other.Method, other.Path)
+			}
+		}
+
+		checked = append(checked, info)
+	}
+
+	return nil
+}
+
+func methodsOverlap(m1, m2 Method) bool {
+	return m1 == m2 || m1 == ANY || m2 == ANY
+}
+
+type routeRelation int
+
+const (
+	routeDistinct routeRelation = iota
+	routeDuplicate
+	routeConflict
+)
+
+// routePathsConflict 判断两个路由路径之间的关系。
+func routePathsConflict(p1, p2 string) routeRelation {
+	if p1 == p2 {
+		return routeDuplicate
+	}
+
+	segs1 := strings.Split(strings.TrimPrefix(p1, "/"), "/")
+	segs2 := strings.Split(strings.TrimPrefix(p2, "/"), "/")
+
+	for i := 0; i < len(segs1) && i < len(segs2); i++ {
+		s1, s2 := segs1[i], segs2[i]
+
+		if s1 == s2 {
+			continue
+		}
+
+		w1, w2 := isWildcardSegment(s1), isWildcardSegment(s2)
+
+		// 两个不同的固定路径不会匹配同一个请求。
+		if !w1 && !w2 {
+			return routeDistinct
+		}
+
+		// 结尾的“/”可以与路径参数共存，例如 /user/ 和 /user/:id。
+		if (s1 == "" && s2[0] == ':') || (s2 == "" && s1[0] == ':') {
+			return routeDistinct
+		}
+
+		return routeConflict
+	}
+
+	if len(segs1) == len(segs2) {
+		return routeDuplicate
+	}
+
+	return routeDistinct
+}
+
+// matchRoutePath 判断请求路径 path 是否匹配 gin 风格的路由 pattern。
+func matchRoutePath(pattern, path string) bool {
+	ps := strings.Split(pattern, "/")
+	segs := strings.Split(path, "/")
+
+	for i, p := range ps {
+		if strings.HasPrefix(p, "*") {
+			return i < len(segs)
+		}
+
+		if i >= len(segs) {
+			return false
+		}
+
+		if strings.HasPrefix(p, ":") {
+			if segs[i] == "" {
+				return false
+			}
+
+			continue
+		}
+
+		if p != segs[i] {
+			return false
+		}
+	}
+
+	return len(ps) == len(segs)
+}
+
+func isWildcardSegment(seg string) bool {
+	return seg != "" && (seg[0] == ':' || seg[0] == '*')
+}
+
+// validateRoutePath 检查路由路径的格式，路径参数必须有名字，通配参数必须在路径的最后。
+func validateRoutePath(path string) error {
+	segs := strings.Split(path, "/")
+
+	for i, seg := range segs {
+		if !isWildcardSegment(seg) {
+			if strings.ContainsAny(seg, ":*") {
+				return errors.New("go-http: wildcard must be a whole path segment")
+			}
+
+			continue
+		}
+
+		if len(seg) == 1 || strings.ContainsAny(seg[1:], ":*") {
+			return errors.New("go-http: wildcard must have a valid name")
+		}
+
+		if seg[0] == '*' && i != len(segs)-1 {
+			return errors.New("go-http: catch-all wildcard must be at the end of path")
+		}
+	}
+
+	return nil
+}
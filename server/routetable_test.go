@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func testRouteTable(ctx context.Context, req *testEngineRequest) (*testEngineResponse, error) {
+	return nil, nil
+}
+
+func TestRoutePathsConflict(t *testing.T) {
+	a := assert.New(t)
+	cases := []struct {
+		p1, p2   string
+		relation routeRelation
+	}{
+		{"/user/login", "/user/login", routeDuplicate},
+		{"/user/login", "/user/logout", routeDistinct},
+		{"/user/:id", "/user/:id", routeDuplicate},
+		{"/user/:id", "/user/:uid", routeConflict},
+		{"/user/:id", "/user/:uid/profile", routeConflict},
+		{"/user/:id", "/user/:id/profile", routeDistinct},
+		{"/user/:id", "/user/list", routeConflict},
+		{"/user/:id", "/user/", routeDistinct},
+		{"/user/:id", "/user", routeDistinct},
+		{"/static/*path", "/static/index.html", routeConflict},
+		{"/static/*path", "/static/", routeConflict},
+		{"/static/*path", "/static", routeDistinct},
+		{"/static/*path", "/static/*file", routeConflict},
+		{"/a/:x", "/:y/b", routeConflict},
+	}
+
+	for _, c := range cases {
+		a.Use(&c)
+		a.Equal(routePathsConflict(c.p1, c.p2), c.relation)
+		a.Equal(routePathsConflict(c.p2, c.p1), c.relation)
+	}
+}
+
+func TestMatchRoutePath(t *testing.T) {
+	a := assert.New(t)
+	cases := []struct {
+		pattern, path string
+		matched       bool
+	}{
+		{"/user/login", "/user/login", true},
+		{"/user/login", "/user/login/", false},
+		{"/user/:id", "/user/12", true},
+		{"/user/:id", "/user/", false},
+		{"/user/:id", "/user/12/profile", false},
+		{"/user/", "/user/", true},
+		{"/user/", "/user", false},
+		{"/static/*path", "/static/", true},
+		{"/static/*path", "/static/js/app.js", true},
+		{"/static/*path", "/static", false},
+	}
+
+	for _, c := range cases {
+		a.Use(&c)
+		a.Equal(matchRoutePath(c.pattern, c.path), c.matched)
+	}
+}
+
+func TestValidateRoutePath(t *testing.T) {
+	a := assert.New(t)
+
+	a.NilError(validateRoutePath("/user/:id/files/*path"))
+	a.NonNilError(validateRoutePath("/user/:"))
+	a.NonNilError(validateRoutePath("/user/id:x"))
+	a.NonNilError(validateRoutePath("/user/:id:x"))
+	a.NonNilError(validateRoutePath("/static/*path/more"))
+}
+
+func TestAddRoutesConflict(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+
+	// 嵌套 RouteMap 中的错误需要返回，并且带上完整的路径。
+	err := s.AddRoutes(RouteMap{
+		"/api": RouteMap{
+			"/user": RouteList{
+				R("login", POST, "not a handler"),
+			},
+		},
+	})
+	a.NonNilError(err)
+	a.Assert(strings.Contains(err.Error(), "[path:/api/user/login]"))
+
+	err = s.AddRoutes(RouteMap{
+		"/user": RouteList{
+			R(":id", GET, testRouteTable),
+			R("list", GET, testRouteTable),
+		},
+	})
+	a.NonNilError(err)
+	a.Assert(strings.Contains(err.Error(), "[path:/user/list]"))
+	a.Assert(strings.Contains(err.Error(), "[existing:GET /user/:id]"))
+
+	// 有冲突时不会注册任何路由。
+	a.Equal(len(s.Routes()), 0)
+
+	a.NilError(s.AddRoutes(RouteList{
+		R("/item/:id", GET, testRouteTable),
+		R("/item/:id", POST, testRouteTable),
+	}))
+	a.Equal(len(s.Routes()), 2)
+
+	// 与已经注册的路由冲突。
+	err = s.AddRoutes(RouteList{
+		R("/item/:id", ANY, testRouteTable),
+	})
+	a.NonNilError(err)
+	a.Assert(strings.Contains(err.Error(), "duplicate route"))
+
+	err = s.AddRoutes(RouteList{
+		R("/item/:name", GET, testRouteTable),
+	})
+	a.NonNilError(err)
+	a.Assert(strings.Contains(err.Error(), "[existing:GET /item/:id]"))
+
+	_, err = ListRoutes(RouteList{
+		R("/static/*path", GET, testRouteTable),
+		R("/static/index.html", GET, testRouteTable),
+	})
+	a.NonNilError(err)
+}
+
+func TestRouteMapOrder(t *testing.T) {
+	a := assert.New(t)
+	routes := RouteMap{
+		"/c": RouteList{R("x", GET, testRouteTable)},
+		"/a": RouteList{R("x", GET, testRouteTable)},
+		"/b": RouteMap{
+			"/z": RouteList{R("x", GET, testRouteTable)},
+			"/y": RouteList{R("x", GET, testRouteTable)},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		list, err := ListRoutes(routes)
+		a.NilError(err)
+
+		paths := make([]string, 0, len(list))
+
+		for _, info := range list {
+			paths = append(paths, info.Path)
+		}
+
+		a.Equal(paths, []string{"/a/x", "/b/y/x", "/b/z/x", "/c/x"})
+	}
+}
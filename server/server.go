@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,31 +12,70 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/gin-gonic/gin"
-
 	"github.com/altstory/go-log"
 )
 
 // Server 代表一个 HTTP 服务。
 type Server struct {
-	server *http.Server
-	engine *gin.Engine
+	server   *http.Server
+	engine   engine
+	registry *routeRegistry
+	config   Config
+
+	admin     *http.Server
+	adminAddr string
+
+	certFile string
+	keyFile  string
+
+	addrs           []string
+	bound           []*boundListener
+	shutdownTimeout time.Duration
+	upgrade         bool
+	upgradeTimeout  time.Duration
+
+	decorators []ContextDecorator
+	capturer   *capturer
+	wsConns    wsConnSet
+	h2cConns   *h2cConnSet
 
-	addr string
+	trustedProxies *trustedProxies
+	panicHooks     []PanicHook
+
+	// err 是 New 检查配置时发现的错误，Serve 和 ServeListener 会直接返回这个错误，拒绝启动服务。
+	err error
 }
 
 // New 创建一个新的 HTTP 服务。
+//
+// 如果 config 中有错误的配置，New 依然会返回一个可以注册路由的 Server，
+// 但 Serve 和 ServeListener 会返回配置错误，拒绝启动服务。
 func New(config *Config) *Server {
 	if config.MaxHeaderBytes <= 0 {
 		config.MaxHeaderBytes = DefaultMaxHeaderBytes
 	}
 
-	if !config.Debug {
-		gin.SetMode(gin.ReleaseMode)
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	if config.BodyReadGracePeriod <= 0 {
+		config.BodyReadGracePeriod = DefaultBodyReadGracePeriod
 	}
 
-	engine := gin.New()
-	engine.Use(gin.Recovery())
+	if config.UpgradeTimeout <= 0 {
+		config.UpgradeTimeout = DefaultUpgradeTimeout
+	}
+
+	var configErrs []error
+	engine, err := newEngine(config.Engine, config.Debug)
+
+	if err != nil {
+		// 依然创建一个引擎，保证注册路由等操作可以正常进行，服务会在 Serve 时报错。
+		log.Errorf(context.Background(), "err=%v||go-http: fail to create engine", err)
+		configErrs = append(configErrs, err)
+		engine, _ = newEngine("", config.Debug)
+	}
 
 	// 如果设置了 ping uri，注册这个 uri。
 	pingURI := config.PingURI
@@ -44,32 +85,142 @@ func New(config *Config) *Server {
 			pingURI = "/" + pingURI
 		}
 
-		engine.GET(pingURI, func(c *gin.Context) {
+		engine.Handle(http.MethodGet, pingURI, func(c *httpContext) {
 			c.Writer.WriteString("OK")
 		})
 	}
 
-	return &Server{
-		server: &http.Server{
-			Addr:    config.Addr,
-			Handler: engine,
+	server := &http.Server{
+		Addr:    config.Addr,
+		Handler: limitBody(engine, config),
+
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
 
-			ReadTimeout:       config.ReadTimeout,
-			ReadHeaderTimeout: config.ReadHeaderTimeout,
-			WriteTimeout:      config.WriteTimeout,
-			IdleTimeout:       config.IdleTimeout,
-			MaxHeaderBytes:    config.MaxHeaderBytes,
-		},
-		engine: engine,
+	h2cConns := &h2cConnSet{}
 
-		addr: config.Addr,
+	if err := configureHTTP2(server, config, h2cConns); err != nil {
+		log.Errorf(context.Background(), "err=%v||go-http: fail to configure HTTP/2", err)
+		configErrs = append(configErrs, err)
 	}
+
+	s := &Server{
+		server:   server,
+		engine:   engine,
+		registry: &routeRegistry{},
+		config:   *config,
+		h2cConns: h2cConns,
+
+		certFile: config.TLSCertFile,
+		keyFile:  config.TLSKeyFile,
+
+		addrs:           listenAddrs(config),
+		shutdownTimeout: config.ShutdownTimeout,
+		upgrade:         config.Upgrade,
+		upgradeTimeout:  config.UpgradeTimeout,
+	}
+
+	engine.Use(s.prepareContext, s.recoverPanic)
+	engine.NoRoute(s.serveNoRoute)
+
+	if tp, err := parseTrustedProxies(config.TrustedProxies); err != nil {
+		log.Errorf(context.Background(), "err=%v||go-http: fail to parse trusted proxies", err)
+		configErrs = append(configErrs, err)
+	} else {
+		s.trustedProxies = tp
+	}
+
+	// 如果设置了录制文件，按照采样率录制流量。
+	if config.CaptureFile != "" {
+		cp, err := newCapturer(config)
+
+		if err != nil {
+			log.Errorf(context.Background(), "err=%v||file=%v||go-http: fail to open capture file", err, config.CaptureFile)
+			configErrs = append(configErrs, err)
+		} else {
+			s.capturer = cp
+			engine.Use(cp.capture)
+		}
+	}
+
+	// 如果设置了 openapi uri，注册接口文档。
+	if openAPIURI := config.OpenAPIURI; openAPIURI != "" {
+		if !strings.HasPrefix(openAPIURI, "/") {
+			openAPIURI = "/" + openAPIURI
+		}
+
+		engine.Handle(http.MethodGet, openAPIURI, s.serveOpenAPI)
+	}
+
+	// 如果设置了 admin uri，注册管理接口。
+	// 管理接口会暴露配置和 pprof 等信息，与业务接口共用地址时必须设置 token。
+	if adminURI := config.AdminURI; adminURI != "" && config.AdminToken == "" && config.AdminAddr == "" {
+		err := errors.New("go-http: admin_token or admin_addr must be set when admin_uri is set")
+		log.Errorf(context.Background(), "err=%v||admin_uri=%v||go-http: refuse to register admin handler", err, adminURI)
+		configErrs = append(configErrs, err)
+	} else if adminURI != "" {
+		if !strings.HasPrefix(adminURI, "/") {
+			adminURI = "/" + adminURI
+		}
+
+		admin := newAdminHandler(s, adminURI, config.AdminToken)
+
+		if config.AdminAddr == "" {
+			engine.Handle("", JoinPaths(adminURI, "/*path"), func(c *httpContext) {
+				admin.ServeHTTP(c.Writer, c.Request)
+			})
+		} else {
+			s.admin = &http.Server{
+				Handler:           admin,
+				ReadHeaderTimeout: config.ReadHeaderTimeout,
+				IdleTimeout:       config.IdleTimeout,
+				MaxHeaderBytes:    config.MaxHeaderBytes,
+			}
+			s.adminAddr = config.AdminAddr
+		}
+	}
+
+	s.err = errors.Join(configErrs...)
+	return s
 }
 
 // AddRoutes 将 routes 路由信息添加到路有里面去。
 func (s *Server) AddRoutes(routes Routes) error {
-	router := newGinRouter(&s.engine.RouterGroup)
-	return routes.Register(router)
+	// 先生成完整的路由表并检查冲突，确认没有问题之后再注册到路由引擎，避免只注册了一部分路由。
+	table := &routeTable{}
+
+	if err := routes.Register(newTableRouter(table)); err != nil {
+		return err
+	}
+
+	if err := table.check(s.registry.list()); err != nil {
+		return err
+	}
+
+	for _, entry := range table.entries {
+		method := entry.info.Method.String()
+
+		if entry.info.Method == ANY {
+			method = ""
+		}
+
+		if err := s.engine.Handle(method, entry.info.Path, entry.handlers...); err != nil {
+			return err
+		}
+
+		s.registry.add(entry.info)
+	}
+
+	return nil
+}
+
+// Routes 返回所有已经注册的路由信息。
+func (s *Server) Routes() []*RouteInfo {
+	return s.registry.list()
 }
 
 // MustAddRoutes 将 routes 路由信息添加到路有里面去，如果过程中发生任何错误，直接 panic。
@@ -82,17 +233,50 @@ func (s *Server) MustAddRoutes(routes Routes) {
 
 // Serve 开始提供 HTTP 服务。这个函数永远不会返回，直到 HTTP 服务终止。
 func (s *Server) Serve() error {
-	errs := make(chan error, 1)
-	go func() {
-		// 开始提供服务。
-		log.Tracef(context.Background(), "addr=%v||http server is starting...", s.addr)
+	if s.err != nil {
+		return s.err
+	}
 
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errs <- err
-		} else {
-			errs <- nil
+	listeners, err := s.listen(s.addrs)
+
+	if err != nil {
+		return err
+	}
+
+	var adminListeners []net.Listener
+
+	if s.admin != nil {
+		adminListeners, err = s.listen([]string{s.adminAddr})
+
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+
+			return err
 		}
-	}()
+	}
+
+	errs := make(chan error, len(listeners)+len(adminListeners))
+
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errs <- s.ServeListener(l)
+		}(l)
+	}
+
+	for _, l := range adminListeners {
+		go func(l net.Listener) {
+			log.Tracef(context.Background(), "addr=%v||network=%v||http admin server is starting...", l.Addr(), l.Addr().Network())
+
+			if err := s.admin.Serve(l); err != nil && err != http.ErrServerClosed {
+				errs <- err
+				return
+			}
+
+			errs <- nil
+		}(l)
+	}
 
 	// 开始统计 goroutine 信息。
 	exitTicker := make(chan bool, 1)
@@ -112,34 +296,154 @@ func (s *Server) Serve() error {
 		}
 	}()
 
+	// 热升级是进程级别的，收到 SIGUSR2 时由 upgrades 统一把所有 Server 的 listener 交给新进程，
+	// 新进程就绪后 upgraded 会被关闭，当前 Server 进行 graceful shutdown。
+	upgraded := upgrades.join(s)
+	defer upgrades.leave(s)
+
+	// 如果当前进程是热升级启动的，所有 Server 都开始提供服务之后通知父进程可以退出了。
+	upgrades.notifyReady()
+
 	// 进行 graceful shutdown。
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	defer signal.Stop(c)
 
+	remaining := len(listeners) + len(adminListeners)
+
 	select {
-	case err := <-errs:
-		return err
+	case err = <-errs:
+		remaining--
 	case <-c:
+	case <-upgraded:
 	}
 
 	// 关闭服务器。
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
-	if err := s.server.Shutdown(ctx); err != nil {
+	if e := s.Shutdown(ctx); e != nil && err == nil {
+		err = e
+	}
+
+	for ; remaining > 0; remaining-- {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// ServeListener 在 l 上提供 HTTP 服务，一般用于测试或者由 sidecar 等外部程序传入 listener 的场景。
+// 这个函数可以与 Serve 同时使用，所有 listener 都会在 Shutdown 时候关闭。
+// 这个函数会一直阻塞，直到 HTTP 服务终止。
+func (s *Server) ServeListener(l net.Listener) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	log.Tracef(context.Background(), "addr=%v||network=%v||tls=%v||http server is starting...", l.Addr(), l.Addr().Network(), s.certFile != "")
+
+	var err error
+
+	if s.certFile != "" {
+		err = s.server.ServeTLS(l, s.certFile, s.keyFile)
+	} else {
+		err = s.server.Serve(l)
+	}
+
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
-	return <-errs
+	return nil
+}
+
+// listen 根据 addrs 创建所有的 listener，任何一个 listener 创建失败都会关闭之前创建的 listener。
+func (s *Server) listen(addrs []string) (listeners []net.Listener, err error) {
+	bound := len(s.bound)
+
+	defer func() {
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+
+			listeners = nil
+			s.bound = s.bound[:bound]
+		}
+	}()
+
+	for _, addr := range addrs {
+		var ls []net.Listener
+		ls, err = listen(addr)
+
+		if err != nil {
+			log.Errorf(context.Background(), "err=%v||addr=%v||go-http: fail to listen", err, addr)
+			return
+		}
+
+		for _, l := range ls {
+			s.bound = append(s.bound, &boundListener{
+				addr:     addr,
+				listener: l,
+			})
+		}
+
+		listeners = append(listeners, ls...)
+	}
+
+	return
+}
+
+func listenAddrs(config *Config) []string {
+	addrs := make([]string, 0, len(config.Listen)+1)
+
+	if config.Addr != "" {
+		addrs = append(addrs, config.Addr)
+	}
+
+	addrs = append(addrs, config.Listen...)
+
+	// 与 http.Server 的行为保持一致，默认监听 :http。
+	if len(addrs) == 0 {
+		addrs = append(addrs, ":http")
+	}
+
+	return addrs
 }
 
 // Shutdown 关闭 HTTP 服务。
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+	var err error
+
+	// 即使管理接口关闭失败，也要继续关闭业务接口。
+	if s.admin != nil {
+		err = s.admin.Shutdown(ctx)
+	}
+
+	// http.Server 不会关闭已经被接管的连接，需要单独关闭所有 WebSocket 连接。
+	// closeAll 之后 wsHandler 不再升级新的连接，正在升级的连接也会在登记时被直接关闭。
+	s.wsConns.closeAll()
+
+	if e := s.server.Shutdown(ctx); e != nil && err == nil {
+		err = e
+	}
+
+	// h2c 连接同样已经被接管，http.Server 只会发送 GOAWAY，需要等待它们结束或者超时后关闭。
+	if e := s.h2cConns.shutdown(ctx); e != nil && err == nil {
+		err = e
+	}
+
+	if s.capturer != nil {
+		s.capturer.close()
+	}
+
+	return err
 }
 
 // Handler 返回一个 http.Handler 用于在外部启动服务。
 func (s *Server) Handler() http.Handler {
-	return s.engine
+	return s.server.Handler
 }
@@ -0,0 +1,198 @@
+// Package servermock 根据路由表创建一个 mock 服务，所有业务函数都会被替换成可以配置的桩函数。
+//
+// mock 服务与真实服务使用完全相同的参数解析规则和应答格式，
+// 可以作为前端或下游服务在本地联调时的替身，也可以在测试中使用。
+//
+//     m := servermock.New(routes.Routes)
+//     m.On(server.POST, "/passport/login").Return(&LoginResponse{...})
+//     m.On(server.POST, "/passport/logout").ReturnError(1001, "not logged in")
+//
+//     // 通过 m.Call 在进程内调用，或者通过 m.Server().Serve() 启动服务。
+//     code, err := m.Call(ctx, server.POST, "/passport/login", &LoginRequest{...}, &resp)
+//     m.On(server.POST, "/passport/login").AssertCalled(t, 1)
+package servermock
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/altstory/go-http/server"
+	"github.com/altstory/go-http/server/servertest"
+)
+
+// Mock 是一个根据路由表生成的 mock 服务。
+// 路由表中的中间件和非业务函数形式的处理函数会保留，只有业务函数会被替换。
+type Mock struct {
+	*servertest.Server
+
+	mu    sync.Mutex
+	stubs map[string]*Stub
+}
+
+// New 使用默认配置创建一个 mock 服务，如果 routes 不合法会 panic。
+func New(routes server.Routes, opts ...servertest.Option) *Mock {
+	return NewWithConfig(&server.Config{}, routes, opts...)
+}
+
+// NewWithConfig 使用 config 创建一个 mock 服务，如果 routes 不合法会 panic。
+func NewWithConfig(config *server.Config, routes server.Routes, opts ...servertest.Option) *Mock {
+	m := &Mock{
+		stubs: map[string]*Stub{},
+	}
+	m.Server = servertest.NewWithConfig(config, &mockRoutes{
+		mock:   m,
+		routes: routes,
+	}, opts...)
+	return m
+}
+
+// On 返回 method 和 path 对应的桩函数，path 是路由的完整路径，例如 "/passport/login"。
+// 如果路由不存在或者不是业务函数会 panic。
+func (m *Mock) On(method server.Method, path string) *Stub {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stub, ok := m.stubs[stubKey(method, path)]
+
+	if !ok {
+		panic(fmt.Errorf("go-http: business route is not found in mock [method:%v] [path:%v]", method, path))
+	}
+
+	return stub
+}
+
+// Stubs 返回所有桩函数，按照路径和请求方法排序。
+func (m *Mock) Stubs() []*Stub {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stubs := make([]*Stub, 0, len(m.stubs))
+
+	for _, stub := range m.stubs {
+		stubs = append(stubs, stub)
+	}
+
+	sort.Slice(stubs, func(i, j int) bool {
+		if stubs[i].path != stubs[j].path {
+			return stubs[i].path < stubs[j].path
+		}
+
+		return stubs[i].method < stubs[j].method
+	})
+	return stubs
+}
+
+// Reset 清空所有桩函数的配置和调用记录。
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stub := range m.stubs {
+		stub.reset()
+	}
+}
+
+// AssertConfiguredCalled 断言所有配置过的桩函数都被调用过至少一次。
+func (m *Mock) AssertConfiguredCalled(t TestingT) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	ok := true
+
+	for _, stub := range m.Stubs() {
+		stub.mock.mu.Lock()
+		configured, called := stub.configured, len(stub.calls)
+		stub.mock.mu.Unlock()
+
+		if configured && called == 0 {
+			t.Errorf("go-http: stub is configured but never called [method:%v] [path:%v]", stub.method, stub.path)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func (m *Mock) addStub(method server.Method, path string, t reflect.Type) reflect.Value {
+	stub := &Stub{
+		mock:   m,
+		method: method,
+		path:   path,
+		typ:    t,
+	}
+
+	m.mu.Lock()
+	m.stubs[stubKey(method, path)] = stub
+	m.mu.Unlock()
+
+	return reflect.MakeFunc(t, stub.invoke)
+}
+
+func stubKey(method server.Method, path string) string {
+	return method.String() + " " + path
+}
+
+// mockRoutes 在注册路由时将所有业务函数替换成桩函数。
+type mockRoutes struct {
+	mock   *Mock
+	routes server.Routes
+}
+
+func (mr *mockRoutes) Register(router server.Router) error {
+	return mr.routes.Register(&mockRouter{
+		mock:   mr.mock,
+		router: router,
+		prefix: "/",
+	})
+}
+
+type mockRouter struct {
+	mock   *Mock
+	router server.Router
+	prefix string
+}
+
+func (mr *mockRouter) SubRouter(uri string, handlers ...server.Handler) (server.Router, error) {
+	sub, err := mr.router.SubRouter(uri, handlers...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &mockRouter{
+		mock:   mr.mock,
+		router: sub,
+		prefix: server.JoinPaths(mr.prefix, uri),
+	}, nil
+}
+
+func (mr *mockRouter) Handle(method server.Method, uri string, handlers ...server.Handler) error {
+	return mr.router.Handle(method, uri, mr.replace(method, uri, handlers)...)
+}
+
+func (mr *mockRouter) HandleAny(uri string, handlers ...server.Handler) error {
+	return mr.router.HandleAny(uri, mr.replace(server.ANY, uri, handlers)...)
+}
+
+// replace 将 handlers 中最后一个业务函数替换成桩函数。
+func (mr *mockRouter) replace(method server.Method, uri string, handlers []server.Handler) []server.Handler {
+	if len(handlers) == 0 {
+		return handlers
+	}
+
+	last := len(handlers) - 1
+
+	if !server.IsBusinessHandler(handlers[last]) {
+		return handlers
+	}
+
+	t := reflect.TypeOf(handlers[last])
+
+	replaced := make([]server.Handler, len(handlers))
+	copy(replaced, handlers)
+	replaced[last] = mr.mock.addStub(method, server.JoinPaths(mr.prefix, uri), t).Interface()
+	return replaced
+}
@@ -0,0 +1,170 @@
+package servermock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/altstory/go-http/server"
+	"github.com/huandu/go-assert"
+)
+
+type testLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type testLoginResponse struct {
+	UID   int64  `json:"uid"`
+	Token string `json:"token"`
+}
+
+type testProfileRequest struct {
+	UID int64 `form:"uid"`
+}
+
+type testProfileResponse struct {
+	Name string `json:"name"`
+}
+
+func testLogin(ctx context.Context, req *testLoginRequest) (*testLoginResponse, error) {
+	panic("should never be called")
+}
+
+func testProfile(ctx context.Context, req testProfileRequest) (testProfileResponse, error) {
+	panic("should never be called")
+}
+
+var testRoutes = server.RouteMap{
+	"/passport": server.RouteList{
+		server.R("login", server.POST, testLogin),
+	},
+	"/user": server.RouteList{
+		server.R("profile", server.GET, testProfile),
+	},
+}
+
+type testT struct {
+	errors []string
+}
+
+func (t *testT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMock(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	m := New(testRoutes)
+	a.Equal(len(m.Stubs()), 2)
+
+	// 没有配置的桩函数返回零值。
+	var login testLoginResponse
+	code, err := m.Call(ctx, server.POST, "/passport/login", &testLoginRequest{Username: "huandu"}, &login)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Equal(login, testLoginResponse{})
+
+	// 固定应答。
+	stub := m.On(server.POST, "/passport/login").Return(testLoginResponse{UID: 906, Token: "token"})
+	code, err = m.Call(ctx, server.POST, "/passport/login", &testLoginRequest{Username: "huandu", Password: "pwd"}, &login)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Equal(login, testLoginResponse{UID: 906, Token: "token"})
+	a.Assert(stub.AssertCalled(t, 2))
+	a.Assert(stub.AssertCalledWith(t, &testLoginRequest{Username: "huandu", Password: "pwd"}))
+
+	// 业务错误码。
+	stub.ReturnError(1001, "invalid password")
+	code, err = m.Call(ctx, server.POST, "/passport/login", &testLoginRequest{}, &login)
+	a.NilError(err)
+	a.Equal(code, 1001)
+
+	// 函数，应答类型不是指针也可以。
+	m.On(server.GET, "/user/profile").Do(func(ctx context.Context, req testProfileRequest) (testProfileResponse, error) {
+		return testProfileResponse{Name: fmt.Sprint("user-", req.UID)}, nil
+	})
+	var profile testProfileResponse
+	code, err = m.Call(ctx, server.GET, "/user/profile", &testProfileRequest{UID: 12}, &profile)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Equal(profile.Name, "user-12")
+	a.Equal(m.On(server.GET, "/user/profile").Calls()[0].Request, testProfileRequest{UID: 12})
+
+	// 断言失败时输出错误。
+	tt := &testT{}
+	a.Assert(!stub.AssertCalled(tt, 1))
+	a.Assert(!stub.AssertCalledWith(tt, testLoginRequest{Username: "nobody"}))
+	a.Equal(len(tt.errors), 2)
+
+	m.Reset()
+	tt = &testT{}
+	a.Assert(m.AssertConfiguredCalled(tt))
+	m.On(server.GET, "/user/profile").Return(&testProfileResponse{Name: "foo"})
+	a.Assert(!m.AssertConfiguredCalled(tt))
+	a.Equal(len(tt.errors), 1)
+}
+
+func TestMockInvalidStub(t *testing.T) {
+	a := assert.New(t)
+	m := New(testRoutes)
+
+	a.Assert(panics(func() { m.On(server.GET, "/not-found") }))
+	a.Assert(panics(func() { m.On(server.POST, "/passport/login").Return(&testProfileResponse{}) }))
+	a.Assert(panics(func() { m.On(server.POST, "/passport/login").Do(testProfile) }))
+}
+
+func panics(fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+		}
+	}()
+
+	fn()
+	return
+}
+
+func TestMockTypedHandler(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	m := New(server.RouteList{
+		server.Handle("login", server.POST, testLogin),
+	})
+	a.Equal(len(m.Stubs()), 1)
+
+	m.On(server.POST, "/login").Return(&testLoginResponse{UID: 906})
+	var login testLoginResponse
+	code, err := m.Call(ctx, server.POST, "/login", &testLoginRequest{Username: "huandu"}, &login)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Equal(login.UID, int64(906))
+	a.Assert(m.On(server.POST, "/login").AssertCalledWith(t, &testLoginRequest{Username: "huandu"}))
+}
+
+type testContext interface {
+	context.Context
+}
+
+type testError interface {
+	error
+}
+
+func testCustomTypes(ctx testContext, req *testProfileRequest) (*testProfileResponse, testError) {
+	panic("should never be called")
+}
+
+func TestMockCustomContextType(t *testing.T) {
+	a := assert.New(t)
+	m := New(server.RouteList{
+		server.R("/user/custom", server.GET, testCustomTypes),
+	})
+	a.Equal(len(m.Stubs()), 1)
+
+	m.On(server.GET, "/user/custom").Return(&testProfileResponse{Name: "custom"})
+	var profile testProfileResponse
+	code, err := m.Call(context.Background(), server.GET, "/user/custom", &testProfileRequest{UID: 1}, &profile)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Equal(profile.Name, "custom")
+}
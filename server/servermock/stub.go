@@ -0,0 +1,195 @@
+package servermock
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/altstory/go-http/server"
+)
+
+// TestingT 是断言需要的测试接口，*testing.T 实现了这个接口。
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Call 是桩函数的一次调用记录。
+type Call struct {
+	Time    time.Time       // Time 是调用的时间。
+	Context context.Context // Context 是框架传给业务函数的 ctx。
+	Request interface{}     // Request 是业务函数收到的请求，类型与业务函数的请求参数一致。
+}
+
+// Stub 是一个业务函数的桩函数。
+//
+// 没有配置过的桩函数会返回应答类型的零值，并且不返回错误。
+type Stub struct {
+	mock   *Mock
+	method server.Method
+	path   string
+	typ    reflect.Type
+
+	// 以下字段都由 mock.mu 保护。
+	configured bool
+	data       reflect.Value
+	err        error
+	fn         reflect.Value
+	calls      []*Call
+}
+
+// Method 返回桩函数对应路由的请求方法。
+func (s *Stub) Method() server.Method {
+	return s.method
+}
+
+// Path 返回桩函数对应路由的完整路径。
+func (s *Stub) Path() string {
+	return s.path
+}
+
+// Return 设置桩函数返回固定的应答，data 的类型必须是业务函数的应答类型或者它的指针。
+func (s *Stub) Return(data interface{}) *Stub {
+	out := s.typ.Out(0)
+	v := reflect.ValueOf(data)
+
+	switch {
+	case v.IsValid() && v.Type() == out:
+	case v.IsValid() && out.Kind() == reflect.Ptr && v.Type() == out.Elem():
+		ptr := reflect.New(out.Elem())
+		ptr.Elem().Set(v)
+		v = ptr
+	case v.IsValid() && v.Kind() == reflect.Ptr && v.Type().Elem() == out:
+		v = v.Elem()
+	default:
+		panic(fmt.Errorf("go-http: type of data doesn't match response type [method:%v] [path:%v] [expected:%v] [actual:%T]", s.method, s.path, out, data))
+	}
+
+	s.set(v, nil, reflect.Value{})
+	return s
+}
+
+// ReturnError 设置桩函数返回业务错误。
+func (s *Stub) ReturnError(code int, msg string) *Stub {
+	s.set(reflect.Value{}, server.Error(code, msg), reflect.Value{})
+	return s
+}
+
+// Do 设置桩函数调用 fn 生成应答，fn 的函数签名必须与业务函数完全一致。
+func (s *Stub) Do(fn interface{}) *Stub {
+	v := reflect.ValueOf(fn)
+
+	if !v.IsValid() || v.Kind() != reflect.Func || !v.Type().ConvertibleTo(s.typ) {
+		panic(fmt.Errorf("go-http: fn must have the same signature as business handler [method:%v] [path:%v] [expected:%v] [actual:%T]", s.method, s.path, s.typ, fn))
+	}
+
+	s.set(reflect.Value{}, nil, v.Convert(s.typ))
+	return s
+}
+
+// Calls 返回桩函数的所有调用记录。
+func (s *Stub) Calls() []*Call {
+	s.mock.mu.Lock()
+	defer s.mock.mu.Unlock()
+
+	calls := make([]*Call, len(s.calls))
+	copy(calls, s.calls)
+	return calls
+}
+
+// AssertCalled 断言桩函数被调用了 times 次。
+func (s *Stub) AssertCalled(t TestingT, times int) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	if n := len(s.Calls()); n != times {
+		t.Errorf("go-http: stub is called %v times, expected %v times [method:%v] [path:%v]", n, times, s.method, s.path)
+		return false
+	}
+
+	return true
+}
+
+// AssertCalledWith 断言桩函数至少有一次调用收到的请求与 req 相同，req 可以是请求类型或者它的指针。
+func (s *Stub) AssertCalledWith(t TestingT, req interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	expected := indirect(req)
+
+	for _, call := range s.Calls() {
+		if reflect.DeepEqual(indirect(call.Request), expected) {
+			return true
+		}
+	}
+
+	t.Errorf("go-http: stub is never called with expected request [method:%v] [path:%v] [request:%+v]", s.method, s.path, expected)
+	return false
+}
+
+func (s *Stub) set(data reflect.Value, err error, fn reflect.Value) {
+	s.mock.mu.Lock()
+	defer s.mock.mu.Unlock()
+
+	s.configured = true
+	s.data = data
+	s.err = err
+	s.fn = fn
+}
+
+func (s *Stub) reset() {
+	s.configured = false
+	s.data = reflect.Value{}
+	s.err = nil
+	s.fn = reflect.Value{}
+	s.calls = nil
+}
+
+// invoke 是桩函数的实现。
+func (s *Stub) invoke(args []reflect.Value) []reflect.Value {
+	ctx := args[0].Interface().(context.Context)
+
+	s.mock.mu.Lock()
+	s.calls = append(s.calls, &Call{
+		Time:    server.Now(ctx),
+		Context: ctx,
+		Request: args[1].Interface(),
+	})
+	data, err, fn := s.data, s.err, s.fn
+	s.mock.mu.Unlock()
+
+	if fn.IsValid() {
+		return fn.Call(args)
+	}
+
+	out := s.typ.Out(0)
+
+	if !data.IsValid() {
+		if out.Kind() == reflect.Ptr {
+			data = reflect.New(out.Elem())
+		} else {
+			data = reflect.Zero(out)
+		}
+	}
+
+	errValue := reflect.New(s.typ.Out(1)).Elem()
+
+	if err != nil {
+		data = reflect.Zero(out)
+		errValue.Set(reflect.ValueOf(err))
+	}
+
+	return []reflect.Value{data, errValue}
+}
+
+func indirect(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return rv.Elem().Interface()
+	}
+
+	return v
+}
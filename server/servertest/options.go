@@ -0,0 +1,85 @@
+package servertest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/altstory/go-http/server"
+)
+
+// Option 用来定制测试请求，可以在 New 的时候设置给所有请求，也可以在 Call 和 Do 的时候设置给单个请求。
+type Option func(o *options)
+
+type options struct {
+	header http.Header
+	values []keyValue
+	clock  server.Clock
+}
+
+type keyValue struct {
+	key, value interface{}
+}
+
+type keyOptionsType struct{}
+
+var keyOptions keyOptionsType
+
+// WithHeader 为请求增加一个 HTTP header。
+func WithHeader(key, value string) Option {
+	return func(o *options) {
+		o.header.Add(key, value)
+	}
+}
+
+// WithValue 在框架为请求创建的 ctx 里设置一个值，
+// 业务代码通过 ctx.Value(key) 可以取到 value，一般用来注入登录用户等身份信息。
+func WithValue(key, value interface{}) Option {
+	return func(o *options) {
+		o.values = append(o.values, keyValue{
+			key:   key,
+			value: value,
+		})
+	}
+}
+
+// WithClock 设置请求使用的时钟，应答里的 now 字段和业务代码中 server.Now 的返回值都会来自这个时钟。
+func WithClock(clock server.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// withOptions 将所有 opts 的设置放到 ctx 里，框架创建请求 ctx 的时候会通过 decorate 读取这些设置。
+func withOptions(ctx context.Context, opts ...[]Option) context.Context {
+	o := &options{
+		header: http.Header{},
+	}
+
+	for _, list := range opts {
+		for _, opt := range list {
+			opt(o)
+		}
+	}
+
+	return context.WithValue(ctx, keyOptions, o)
+}
+
+func optionsFromContext(ctx context.Context) *options {
+	o, _ := ctx.Value(keyOptions).(*options)
+	return o
+}
+
+// decorate 是注册给 server.Server 的 ContextDecorator。
+func decorate(ctx context.Context, r *http.Request) context.Context {
+	o := optionsFromContext(r.Context())
+
+	if o == nil {
+		return ctx
+	}
+
+	for _, kv := range o.values {
+		ctx = context.WithValue(ctx, kv.key, kv.value)
+	}
+
+	return server.WithClock(ctx, o.clock)
+}
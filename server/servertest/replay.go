@@ -0,0 +1,236 @@
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/altstory/go-http/server"
+)
+
+// ReplayResult 是回放一条录制记录的结果。
+type ReplayResult struct {
+	Record  *server.CaptureRecord // Record 是被回放的记录。
+	Status  int                   // Status 是回放时的 HTTP 状态码。
+	Body    []byte                // Body 是回放时的应答。
+	Skipped bool                  // Skipped 表示记录中的 body 不完整，没有回放。
+	Diffs   []string              // Diffs 是回放结果与录制结果的差异，为空表示一致。
+}
+
+// OK 判断回放结果是否与录制结果一致。
+func (rr *ReplayResult) OK() bool {
+	return len(rr.Diffs) == 0
+}
+
+// String 返回回放结果的描述，方便输出到测试日志中。
+func (rr *ReplayResult) String() string {
+	req := rr.Record.Request
+
+	if rr.Skipped {
+		return fmt.Sprintf("%v %v: skipped", req.Method, req.URL)
+	}
+
+	if rr.OK() {
+		return fmt.Sprintf("%v %v: ok", req.Method, req.URL)
+	}
+
+	return fmt.Sprintf("%v %v:\n    %v", req.Method, req.URL, strings.Join(rr.Diffs, "\n    "))
+}
+
+// ReplayFile 读取 path 中的录制记录并通过 Replay 回放。
+func ReplayFile(h http.Handler, path string, ignore ...string) ([]*ReplayResult, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return Replay(h, f, ignore...)
+}
+
+// Replay 读取 r 中的录制记录，逐条发给 h 处理，并将应答与录制的应答进行对比。
+//
+// JSON 应答会按照字段逐个对比，应答中的 now 字段总是被忽略，
+// ignore 可以设置更多需要忽略的字段，字段路径用“.”分隔，例如 "data.created_at"，
+// 数组中的元素用下标表示，例如 "data.list.0.id"，用 "*" 可以匹配任意字段名或下标。
+func Replay(h http.Handler, r io.Reader, ignore ...string) ([]*ReplayResult, error) {
+	records, err := server.ReadCaptureRecords(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := append([]string{"now"}, ignore...)
+	results := make([]*ReplayResult, 0, len(records))
+
+	for _, record := range records {
+		results = append(results, replay(h, record, ignored))
+	}
+
+	return results, nil
+}
+
+func replay(h http.Handler, record *server.CaptureRecord, ignored []string) *ReplayResult {
+	result := &ReplayResult{
+		Record: record,
+	}
+	req := record.Request
+	res := record.Response
+
+	if req == nil || res == nil || req.Truncated || res.Truncated {
+		result.Skipped = true
+		return result
+	}
+
+	r := httptest.NewRequest(req.Method, req.URL, strings.NewReader(req.Body))
+
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	resp := rec.Result()
+	defer resp.Body.Close()
+	result.Status = resp.StatusCode
+	result.Body, _ = ioutil.ReadAll(resp.Body)
+
+	if result.Status != res.Status {
+		result.Diffs = append(result.Diffs, fmt.Sprintf("status: expected %v, actual %v", res.Status, result.Status))
+	}
+
+	if len(res.Envelope) == 0 {
+		if !bytes.Equal(result.Body, []byte(res.Body)) {
+			result.Diffs = append(result.Diffs, fmt.Sprintf("body: expected %q, actual %q", res.Body, result.Body))
+		}
+
+		return result
+	}
+
+	var expected, actual interface{}
+	json.Unmarshal(res.Envelope, &expected)
+
+	if err := json.Unmarshal(result.Body, &actual); err != nil {
+		result.Diffs = append(result.Diffs, fmt.Sprintf("body: expected JSON, actual %q", result.Body))
+		return result
+	}
+
+	diffJSON(&result.Diffs, nil, expected, actual, ignored)
+	return result
+}
+
+// diffJSON 对比 JSON 解码后的 expected 和 actual，将所有差异写入 diffs。
+func diffJSON(diffs *[]string, path []string, expected, actual interface{}, ignored []string) {
+	if isIgnored(path, ignored) {
+		return
+	}
+
+	p := strings.Join(path, ".")
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(e)+len(a))
+
+		for k := range e {
+			keys = append(keys, k)
+		}
+
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			ev, eok := e[k]
+			av, aok := a[k]
+			sub := append(path[:len(path):len(path)], k)
+
+			if isIgnored(sub, ignored) {
+				continue
+			}
+
+			switch {
+			case !aok:
+				*diffs = append(*diffs, fmt.Sprintf("%v: missing field", strings.Join(sub, ".")))
+			case !eok:
+				*diffs = append(*diffs, fmt.Sprintf("%v: unexpected field", strings.Join(sub, ".")))
+			default:
+				diffJSON(diffs, sub, ev, av, ignored)
+			}
+		}
+
+		return
+
+	case []interface{}:
+		a, ok := actual.([]interface{})
+
+		if !ok {
+			break
+		}
+
+		if len(e) != len(a) {
+			*diffs = append(*diffs, fmt.Sprintf("%v: expected %v elements, actual %v", p, len(e), len(a)))
+			return
+		}
+
+		for i := range e {
+			diffJSON(diffs, append(path[:len(path):len(path)], fmt.Sprint(i)), e[i], a[i], ignored)
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		ej, _ := json.Marshal(expected)
+		aj, _ := json.Marshal(actual)
+		*diffs = append(*diffs, fmt.Sprintf("%v: expected %s, actual %s", p, ej, aj))
+	}
+}
+
+func isIgnored(path []string, ignored []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	for _, pattern := range ignored {
+		parts := strings.Split(pattern, ".")
+
+		if len(parts) != len(path) {
+			continue
+		}
+
+		matched := true
+
+		for i, part := range parts {
+			if part != "*" && part != path[i] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,92 @@
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/altstory/go-http/server"
+	"github.com/huandu/go-assert"
+)
+
+type testItemRequest struct {
+	ID int `form:"id"`
+}
+
+type testItemResponse struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Token string   `json:"token"`
+}
+
+func newReplayRoutes(name string, tags ...string) server.Routes {
+	return server.RouteMap{
+		"/item": server.RouteList{
+			server.R("get", server.GET, func(ctx context.Context, req *testItemRequest) (*testItemResponse, error) {
+				return &testItemResponse{
+					ID:    req.ID,
+					Name:  name,
+					Tags:  tags,
+					Token: server.Now(ctx).String(),
+				}, nil
+			}),
+		},
+	}
+}
+
+func TestReplay(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-http-test")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "capture.jsonl")
+	ts := NewWithConfig(&server.Config{
+		CaptureFile: file,
+	}, newReplayRoutes("foo", "a", "b"))
+
+	var res testItemResponse
+	code, err := ts.Call(context.Background(), "GET", "/item/get", &testItemRequest{ID: 1}, &res)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.NilError(ts.Server().Shutdown(context.Background()))
+
+	// 行为相同的服务回放结果一致，token 每次都会变化，需要忽略。
+	same := New(newReplayRoutes("foo", "a", "b"))
+	results, err := ReplayFile(same.Server().Handler(), file, "data.token")
+	a.NilError(err)
+	a.Equal(len(results), 1)
+	a.Assert(results[0].OK())
+
+	// 行为不同的服务会输出差异。
+	changed := New(newReplayRoutes("bar", "a"))
+	results, err = ReplayFile(changed.Server().Handler(), file, "data.token")
+	a.NilError(err)
+	a.Equal(len(results), 1)
+	a.Equal(results[0].Diffs, []string{
+		`data.name: expected "foo", actual "bar"`,
+		`data.tags: expected 2 elements, actual 1`,
+	})
+}
+
+func TestReplayRawBody(t *testing.T) {
+	a := assert.New(t)
+	records := `{"request":{"method":"GET","url":"/raw","header":{}},"response":{"status":200,"header":{},"body":"hello"}}` + "\n" +
+		`{"request":{"method":"GET","url":"/raw","header":{},"truncated":true},"response":{"status":200,"header":{}}}` + "\n"
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("world"))
+	})
+	results, err := Replay(h, bytes.NewReader([]byte(records)))
+	a.NilError(err)
+	a.Equal(len(results), 2)
+	a.Equal(results[0].Diffs, []string{`body: expected "hello", actual "world"`})
+	a.Assert(results[1].Skipped)
+	a.Assert(strings.HasSuffix(results[1].String(), "skipped"))
+}
@@ -0,0 +1,110 @@
+// Package servertest 提供在进程内测试路由的工具，不需要启动任何网络监听。
+//
+// 所有请求都会经过真实的参数解析、中间件和应答封装逻辑，只是不经过网络。
+//
+//     ts := servertest.New(routes.Routes)
+//
+//     var resp LoginResponse
+//     code, err := ts.Call(ctx, "POST", "/passport/login", &LoginRequest{...}, &resp)
+package servertest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/altstory/go-http/client"
+	"github.com/altstory/go-http/server"
+)
+
+// baseURL 是进程内请求使用的虚拟地址。
+const baseURL = "http://servertest"
+
+// Server 是一个进程内的测试服务。
+type Server struct {
+	server  *server.Server
+	handler http.Handler
+	client  *client.Client
+	opts    []Option
+}
+
+// New 使用默认配置创建一个测试服务并注册 routes，如果注册失败会 panic。
+func New(routes server.Routes, opts ...Option) *Server {
+	return NewWithConfig(&server.Config{}, routes, opts...)
+}
+
+// NewWithConfig 使用 config 创建一个测试服务并注册 routes，如果注册失败会 panic。
+// config 里所有与网络监听相关的配置都不会生效。
+func NewWithConfig(config *server.Config, routes server.Routes, opts ...Option) *Server {
+	s := server.New(config)
+
+	if err := s.AddRoutes(routes); err != nil {
+		panic(err)
+	}
+
+	ts := &Server{
+		server:  s,
+		handler: s.Handler(),
+		opts:    opts,
+	}
+	ts.client = client.New(&client.Config{
+		BaseURL:   baseURL,
+		Timeout:   time.Hour,
+		Transport: roundTripperFunc(ts.roundTrip),
+	})
+	s.DecorateContext(decorate)
+	return ts
+}
+
+// Server 返回测试服务使用的 server.Server。
+func (ts *Server) Server() *server.Server {
+	return ts.server
+}
+
+// Call 调用 uri 对应的业务接口，req 和 res 的用法与 client.Client 的 Call 一致。
+//
+// 如果服务返回了业务错误，code 是业务错误码，err 为 nil；
+// 如果请求失败或者应答格式不正确，err 不为 nil。
+// opts 会追加在 New 时设置的 Option 之后。
+func (ts *Server) Call(ctx context.Context, method server.Method, uri string, req, res interface{}, opts ...Option) (code int, err error) {
+	ctx = withOptions(ctx, ts.opts, opts)
+	err = ts.client.Call(ctx, method, uri, req, res)
+
+	if err == nil {
+		return server.ErrCodeOK, nil
+	}
+
+	if server.IsBusinessError(err) {
+		return server.ErrorCode(err), nil
+	}
+
+	return server.ErrorCode(err), err
+}
+
+// Do 在进程内处理 r 并返回应答，适合测试非业务函数形式的路由。
+func (ts *Server) Do(r *http.Request, opts ...Option) *http.Response {
+	return ts.serve(r.WithContext(withOptions(r.Context(), ts.opts, opts)))
+}
+
+func (ts *Server) serve(r *http.Request) *http.Response {
+	if o := optionsFromContext(r.Context()); o != nil {
+		for k, v := range o.header {
+			r.Header[k] = v
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, r)
+	return rec.Result()
+}
+
+func (ts *Server) roundTrip(r *http.Request) (*http.Response, error) {
+	return ts.serve(r), nil
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
@@ -0,0 +1,127 @@
+package servertest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/altstory/go-http/server"
+	"github.com/huandu/go-assert"
+)
+
+type testPrincipalKey struct{}
+
+type testLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type testWhoAmIRequest struct{}
+
+type testResponse struct {
+	Name string    `json:"name"`
+	Now  time.Time `json:"now"`
+}
+
+func testLogin(ctx context.Context, req *testLoginRequest) (*testResponse, error) {
+	if req.Password != "secret" {
+		return nil, server.Error(100, "invalid password")
+	}
+
+	return &testResponse{Name: req.Username, Now: server.Now(ctx)}, nil
+}
+
+func testWhoAmI(ctx context.Context, req *testWhoAmIRequest) (*testResponse, error) {
+	name, _ := ctx.Value(testPrincipalKey{}).(string)
+
+	if name == "" {
+		return nil, server.Error(401, "not logged in")
+	}
+
+	return &testResponse{Name: name}, nil
+}
+
+func testEcho(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Header.Get("X-Echo")))
+}
+
+var testRoutes = server.RouteMap{
+	"/passport": server.RouteList{
+		server.R("login", server.POST, testLogin),
+		server.R("whoami", server.GET, testWhoAmI),
+		server.R("echo", server.GET, testEcho),
+	},
+}
+
+func TestCall(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	ts := New(testRoutes)
+
+	var res testResponse
+	code, err := ts.Call(ctx, "POST", "/passport/login", &testLoginRequest{
+		Username: "huandu",
+		Password: "secret",
+	}, &res)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Equal(res.Name, "huandu")
+
+	code, err = ts.Call(ctx, "POST", "/passport/login", &testLoginRequest{}, &res)
+	a.NilError(err)
+	a.Equal(code, 100)
+
+	// 接口不存在时同样返回标准格式的应答。
+	code, err = ts.Call(ctx, "POST", "/passport/not-found", &testLoginRequest{}, &res)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeNotFound)
+}
+
+func TestOptions(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ts := New(testRoutes, WithClock(func() time.Time {
+		return now
+	}))
+
+	// 注入登录用户。
+	var res testResponse
+	code, err := ts.Call(ctx, "GET", "/passport/whoami", nil, &res)
+	a.NilError(err)
+	a.Equal(code, 401)
+
+	code, err = ts.Call(ctx, "GET", "/passport/whoami", nil, &res, WithValue(testPrincipalKey{}, "huandu"))
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Equal(res.Name, "huandu")
+
+	// 业务代码和应答中的 now 都使用假时钟。
+	r, _ := http.NewRequest(http.MethodPost, "/passport/login", nil)
+	r.Header.Set("Content-Type", "application/json")
+	resp := ts.Do(r)
+	data, err := ioutil.ReadAll(resp.Body)
+	a.NilError(err)
+
+	var env struct {
+		Err int    `json:"err"`
+		Now string `json:"now"`
+	}
+	a.NilError(json.Unmarshal(data, &env))
+	a.Equal(env.Now, now.Format(time.RFC3339))
+
+	code, err = ts.Call(ctx, "POST", "/passport/login", &testLoginRequest{Password: "secret"}, &res)
+	a.NilError(err)
+	a.Equal(code, server.ErrCodeOK)
+	a.Assert(res.Now.Equal(now))
+
+	// 注入 header。
+	r, _ = http.NewRequest(http.MethodGet, "/passport/echo", nil)
+	resp = ts.Do(r, WithHeader("X-Echo", "hello"))
+	data, err = ioutil.ReadAll(resp.Body)
+	a.NilError(err)
+	a.Equal(string(data), "hello")
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+// DefaultSSEHeartbeatInterval 是 Server-Sent Events 默认的心跳间隔。
+const DefaultSSEHeartbeatInterval = 15 * time.Second
+
+// HeaderLastEventID 是客户端重连时带上的最后一个事件 id。
+const HeaderLastEventID = "Last-Event-ID"
+
+var typeOfEventStream = reflect.TypeOf((*EventStream)(nil)).Elem()
+
+// Event 是一个 Server-Sent Events 事件。
+type Event struct {
+	ID    string        // ID 是事件 id，客户端重连时会通过 Last-Event-ID header 带上最后收到的 id。
+	Event string        // Event 是事件类型，为空表示默认的 message 类型。
+	Data  interface{}   // Data 是事件内容，string 和 []byte 会原样发送，其他类型会编码成 JSON。
+	Retry time.Duration // Retry 设置客户端断开后的重连间隔，为 0 表示不设置。
+}
+
+// EventStream 是一个 Server-Sent Events 事件流。
+//
+// 客户端断开连接之后，传给处理函数的 ctx 会被取消，Send 也会返回错误，处理函数应该尽快返回。
+type EventStream interface {
+	// Send 发送一个事件，event 是事件类型，为空表示默认的 message 类型。
+	// data 是 string 或 []byte 时会原样发送，其他类型会编码成 JSON。
+	Send(event string, data interface{}) error
+
+	// SendEvent 发送一个完整的事件，可以设置事件 id 和重连间隔。
+	SendEvent(e *Event) error
+
+	// LastEventID 返回客户端通过 Last-Event-ID header 传入的最后一个事件 id，
+	// 处理函数可以根据这个 id 补发客户端断线期间错过的事件。
+	LastEventID() string
+}
+
+type eventStream struct {
+	ctx          context.Context
+	writer       responseWriter
+	lastEventID  string
+	writeTimeout time.Duration
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (es *eventStream) Send(event string, data interface{}) error {
+	return es.SendEvent(&Event{
+		Event: event,
+		Data:  data,
+	})
+}
+
+func (es *eventStream) SendEvent(e *Event) error {
+	if e == nil {
+		return errors.New("go-http: event must not be nil")
+	}
+
+	if strings.ContainsAny(e.ID, "\r\n") || strings.ContainsAny(e.Event, "\r\n") {
+		return errors.New("go-http: event id and type must not contain line breaks")
+	}
+
+	var data []byte
+
+	switch d := e.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		var err error
+		data, err = json.Marshal(d)
+
+		if err != nil {
+			return fmt.Errorf("go-http: fail to encode event data in JSON [err:%v]", err)
+		}
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.buf.Reset()
+
+	if e.ID != "" {
+		fmt.Fprintf(&es.buf, "id: %v\n", e.ID)
+	}
+
+	if e.Event != "" {
+		fmt.Fprintf(&es.buf, "event: %v\n", e.Event)
+	}
+
+	if e.Retry > 0 {
+		fmt.Fprintf(&es.buf, "retry: %v\n", int64(e.Retry/time.Millisecond))
+	}
+
+	// 多行数据需要拆成多个 data 字段，客户端会用换行符把它们拼起来。
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+
+	for _, line := range lines {
+		fmt.Fprintf(&es.buf, "data: %v\n", line)
+	}
+
+	es.buf.WriteByte('\n')
+	return es.flush()
+}
+
+func (es *eventStream) LastEventID() string {
+	return es.lastEventID
+}
+
+// heartbeat 发送一个注释行，避免连接因为空闲被中间的代理断开。
+func (es *eventStream) heartbeat() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.buf.Reset()
+	es.buf.WriteString(": ping\n\n")
+	return es.flush()
+}
+
+// flush 将 es.buf 写入连接，调用者需要持有 es.mu。
+func (es *eventStream) flush() error {
+	if err := es.ctx.Err(); err != nil {
+		return err
+	}
+
+	// 事件流可能持续很久，每次输出都重新计算写超时。
+	extendWriteDeadline(es.writer, es.writeTimeout)
+
+	if _, err := es.writer.Write(es.buf.Bytes()); err != nil {
+		return err
+	}
+
+	es.writer.Flush()
+	return nil
+}
+
+func parseEventStreamHandler(v reflect.Value) (handlerFunc, error) {
+	t := v.Type()
+
+	if !isContextType(t.In(0)) {
+		return nil, errors.New("go-http: type of the handler is not supported")
+	}
+
+	plan := newBindingPlan(t.In(1))
+
+	if plan.typ.Kind() != reflect.Struct {
+		return nil, errors.New("go-http: type of the handler is not supported")
+	}
+
+	if t.In(2) != typeOfEventStream {
+		return nil, errors.New("go-http: type of the handler is not supported")
+	}
+
+	if t.Out(0) != typeOfError {
+		return nil, errors.New("go-http: type of the handler is not supported")
+	}
+
+	return wrapHandlerFunc(func(c *httpContext) {
+		ctx := c.Request.Context()
+		vIn, em := bindRequest(c, plan)
+
+		if em != nil {
+			writeResponse(ctx, c, bindStatus(em), em.ToH(nil))
+			return
+		}
+
+		heartbeatInterval := DefaultSSEHeartbeatInterval
+
+		if s := serverFrom(c); s != nil && s.config.SSEHeartbeatInterval > 0 {
+			heartbeatInterval = s.config.SSEHeartbeatInterval
+		}
+
+		// 客户端断开连接之后取消 ctx。
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		reqCtx := requestContextFrom(c)
+
+		header := c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no")
+		timeout := writeTimeout(c)
+		extendWriteDeadline(c.Writer, timeout)
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Flush()
+
+		stream := &eventStream{
+			ctx:          ctx,
+			writer:       c.Writer,
+			lastEventID:  c.GetHeader(HeaderLastEventID),
+			writeTimeout: timeout,
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			ticker := time.NewTicker(heartbeatInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-reqCtx.Done():
+					cancel()
+					return
+				case <-ticker.C:
+					if err := stream.heartbeat(); err != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		returns := v.Call([]reflect.Value{reflect.ValueOf(ctx), vIn, reflect.ValueOf(stream)})
+		disconnected := reqCtx.Err() != nil
+		cancel()
+		<-done
+
+		err, _ := returns[0].Interface().(error)
+		code := ErrCodeOK
+
+		// 处理函数返回错误时，如果客户端还在线，通过 error 事件告诉客户端错误信息。
+		if err != nil {
+			em, ok := err.(*errorMsg)
+
+			if !ok {
+				em = newErrorMsg(ErrCodeInvalidError, fmt.Sprintf("go-http: business returns an invalid error: %v", err))
+			}
+
+			code = em.code
+
+			if !disconnected {
+				data := em.ToH(nil)
+				data["now"] = Now(ctx).Format(time.RFC3339)
+				stream.ctx = context.Background()
+				stream.Send("error", data)
+			}
+		}
+
+		log.Tracef(ctx, "disconnected=%v||last_event_id=%v||go-http: event stream ends", disconnected, stream.lastEventID)
+		reportResponse(ctx, c, code)
+	}), nil
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testSSERequest struct {
+	Topic string `form:"topic"`
+	Count int    `form:"count"`
+}
+
+func readSSE(r *bufio.Reader, lines int) ([]string, error) {
+	var result []string
+
+	for len(result) < lines {
+		line, err := r.ReadString('\n')
+
+		if err != nil {
+			return result, err
+		}
+
+		result = append(result, strings.TrimRight(line, "\n"))
+	}
+
+	return result, nil
+}
+
+func TestEventStream(t *testing.T) {
+	a := assert.New(t)
+	exited := make(chan error, 1)
+
+	s := New(&Config{
+		SSEHeartbeatInterval: 20 * time.Millisecond,
+	})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/events": RouteList{
+			R("feed", GET, func(ctx context.Context, req *testSSERequest, stream EventStream) error {
+				for i := 0; i < req.Count; i++ {
+					if err := stream.SendEvent(&Event{
+						ID:    stream.LastEventID() + "+",
+						Event: req.Topic,
+						Data:  map[string]int{"seq": i},
+					}); err != nil {
+						return err
+					}
+				}
+
+				stream.Send("", "multi\nline")
+
+				// 等待客户端断开。
+				<-ctx.Done()
+				exited <- ctx.Err()
+				return nil
+			}),
+			R("fail", GET, func(ctx context.Context, req *testSSERequest, stream EventStream) error {
+				return Error(1234, "failed")
+			}),
+		},
+	}))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	r, err := http.NewRequest(http.MethodGet, ts.URL+"/events/feed?topic=news&count=2", nil)
+	a.NilError(err)
+	r.Header.Set(HeaderLastEventID, "42")
+	resp, err := http.DefaultClient.Do(r)
+	a.NilError(err)
+	a.Equal(resp.Header.Get("Content-Type"), "text/event-stream")
+
+	reader := bufio.NewReader(resp.Body)
+	lines, err := readSSE(reader, 11)
+	a.NilError(err)
+	a.Equal(lines, []string{
+		"id: 42+", "event: news", `data: {"seq":0}`, "",
+		"id: 42+", "event: news", `data: {"seq":1}`, "",
+		"data: multi", "data: line", "",
+	})
+
+	// 空闲的时候会发送心跳。
+	lines, err = readSSE(reader, 2)
+	a.NilError(err)
+	a.Equal(lines, []string{": ping", ""})
+
+	// 客户端断开后 ctx 会被取消。
+	resp.Body.Close()
+
+	select {
+	case err := <-exited:
+		a.Equal(err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatalf("handler is not canceled after client disconnects")
+	}
+
+	// 处理函数返回错误时发送 error 事件。
+	resp, err = http.Get(ts.URL + "/events/fail")
+	a.NilError(err)
+	defer resp.Body.Close()
+	lines, err = readSSE(bufio.NewReader(resp.Body), 2)
+	a.NilError(err)
+	a.Equal(lines[0], "event: error")
+	a.Assert(strings.HasPrefix(lines[1], `data: {"err":1234,`))
+
+	// 参数错误时返回普通的 JSON 应答。
+	resp, err = http.Get(ts.URL + "/events/feed?count=abc")
+	a.NilError(err)
+	resp.Body.Close()
+	a.Equal(resp.StatusCode, http.StatusBadRequest)
+}
+
+func TestEventStreamWriteTimeout(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		WriteTimeout:         200 * time.Millisecond,
+		SSEHeartbeatInterval: time.Hour,
+	})
+	a.NilError(s.AddRoutes(RouteList{
+		R("/events", GET, func(ctx context.Context, req *testSSERequest, stream EventStream) error {
+			for i := 0; i < req.Count; i++ {
+				time.Sleep(100 * time.Millisecond)
+
+				if err := stream.Send("", "tick"); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}),
+	}))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NilError(err)
+	go s.ServeListener(l)
+	defer s.Shutdown(context.Background())
+
+	// 事件流持续的时间远远超过 WriteTimeout，但每个事件之间的间隔没有超过，连接不会被断开。
+	resp, err := http.Get("http://" + l.Addr().String() + "/events?count=6")
+	a.NilError(err)
+	defer resp.Body.Close()
+	lines, err := readSSE(bufio.NewReader(resp.Body), 12)
+	a.NilError(err)
+	a.Equal(lines[10], "data: tick")
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+const (
+	// MIMENDJSON 是 NDJSON 的 Content-Type，请求的 Accept header 包含这个类型时，流式应答会使用 NDJSON 格式。
+	MIMENDJSON = "application/x-ndjson"
+
+	// HeaderStreamErr 是 NDJSON 格式的流式应答结束时通过 HTTP trailer 返回的业务错误码。
+	HeaderStreamErr = "X-Stream-Err"
+
+	// HeaderStreamMsg 是 NDJSON 格式的流式应答结束时通过 HTTP trailer 返回的错误信息。
+	HeaderStreamMsg = "X-Stream-Msg"
+
+	// streamFlushItems 是流式应答每输出多少个元素 flush 一次。
+	streamFlushItems = 64
+)
+
+var typeOfIterator = reflect.TypeOf((*Iterator)(nil)).Elem()
+
+// Iterator 是一个逐个返回元素的迭代器。
+//
+// 业务函数可以返回 Iterator 或者 `<-chan *U` 来流式输出大量数据，框架会边读取边输出，不需要把所有数据都放在内存里。
+// 默认的应答格式是 `{"now":"","data":[...],"err":0,"msg":""}`，与普通业务函数的应答结构一致，只是 err 放在了 data 之后；
+// 如果请求的 Accept header 包含 MIMENDJSON，应答会使用 NDJSON 格式，每行一个元素，
+// 业务错误码和错误信息通过 HeaderStreamErr 和 HeaderStreamMsg 这两个 HTTP trailer 返回。
+//
+// 客户端断开连接之后，传给业务函数的 ctx 会被取消。
+// 使用 channel 时，业务代码需要在 ctx 取消后停止写入并关闭 channel，否则写入的 goroutine 会一直阻塞。
+type Iterator interface {
+	// Next 返回下一个元素，没有更多元素时返回 io.EOF。
+	// 返回其他错误会中止输出，如果是通过 Error 构造的业务错误，错误码会返回给客户端。
+	Next(ctx context.Context) (item interface{}, err error)
+}
+
+// IteratorFunc 是一个函数形式的 Iterator。
+type IteratorFunc func(ctx context.Context) (item interface{}, err error)
+
+// Next 调用 fn 返回下一个元素。
+func (fn IteratorFunc) Next(ctx context.Context) (interface{}, error) {
+	return fn(ctx)
+}
+
+// isStreamResponse 判断业务函数的应答类型是否是流式应答。
+func isStreamResponse(t reflect.Type) bool {
+	return t == typeOfIterator || (t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0)
+}
+
+func wrapStreamHandler(v reflect.Value) (handlerFunc, error) {
+	plan := newBindingPlan(v.Type().In(1))
+
+	return wrapHandlerFunc(func(c *httpContext) {
+		ctx := c.Request.Context()
+		vIn, em := bindRequest(c, plan)
+
+		if em != nil {
+			writeResponse(ctx, c, bindStatus(em), em.ToH(nil))
+			return
+		}
+
+		// 客户端断开连接之后取消 ctx，让数据源尽快停止。
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		reqCtx := requestContextFrom(c)
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-reqCtx.Done():
+				cancel()
+			case <-stop:
+			}
+		}()
+
+		returns := v.Call([]reflect.Value{reflect.ValueOf(ctx), vIn})
+
+		if err, _ := returns[1].Interface().(error); err != nil {
+			em, ok := err.(*errorMsg)
+
+			if !ok {
+				writeResponse(ctx, c, http.StatusInternalServerError, newErrorMsg(ErrCodeInvalidError, fmt.Sprintf("go-http: business returns an invalid error: %v", err)).ToH(nil))
+				return
+			}
+
+			writeResponse(ctx, c, http.StatusOK, em.ToH(nil))
+			return
+		}
+
+		sw := &streamWriter{
+			ctx:          ctx,
+			c:            c,
+			ndjson:       strings.Contains(c.GetHeader("Accept"), MIMENDJSON),
+			writeTimeout: writeTimeout(c),
+		}
+		em = sw.run(streamNext(ctx, returns[0], sw.flush))
+		code := ErrCodeOK
+
+		if em != nil {
+			code = em.code
+		}
+
+		log.Tracef(ctx, "items=%v||ndjson=%v||err=%v||go-http: stream ends", sw.items, sw.ndjson, em)
+		reportResponse(ctx, c, code)
+	}), nil
+}
+
+// streamNext 将业务函数返回的 channel 或 Iterator 转换成统一的读取函数。
+// 从 channel 读取时，如果需要等待，会先调用 flush 把已经输出的数据发出去。
+func streamNext(ctx context.Context, v reflect.Value, flush func()) func() (interface{}, error) {
+	if v.Kind() == reflect.Interface {
+		it, _ := v.Interface().(Iterator)
+
+		if it == nil {
+			return func() (interface{}, error) {
+				return nil, io.EOF
+			}
+		}
+
+		return func() (interface{}, error) {
+			return it.Next(ctx)
+		}
+	}
+
+	if v.IsNil() {
+		return func() (interface{}, error) {
+			return nil, io.EOF
+		}
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: v},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+
+	return func() (interface{}, error) {
+		x, ok := v.TryRecv()
+
+		if !x.IsValid() {
+			flush()
+			var chosen int
+			chosen, x, ok = reflect.Select(cases)
+
+			if chosen == 1 {
+				return nil, ctx.Err()
+			}
+		}
+
+		if !ok {
+			return nil, io.EOF
+		}
+
+		return x.Interface(), nil
+	}
+}
+
+// streamWriter 负责输出流式应答。
+type streamWriter struct {
+	ctx          context.Context
+	c            *httpContext
+	ndjson       bool
+	items        int
+	writeTimeout time.Duration
+}
+
+func (sw *streamWriter) flush() {
+	sw.c.Writer.Flush()
+}
+
+// run 读取所有元素并输出，返回读取或输出过程中遇到的错误。
+func (sw *streamWriter) run(next func() (interface{}, error)) *errorMsg {
+	w := sw.c.Writer
+	header := w.Header()
+	applyResponse(sw.ctx, sw.c, http.StatusOK, nil)
+
+	if sw.ndjson {
+		header.Set("Content-Type", MIMENDJSON)
+		header.Set("Trailer", HeaderStreamErr+", "+HeaderStreamMsg)
+	} else {
+		header.Set("Content-Type", mimeJSON+"; charset=utf-8")
+		fmt.Fprintf(w, `{"now":%q,"data":[`, Now(sw.ctx).Format(time.RFC3339))
+	}
+
+	extendWriteDeadline(w, sw.writeTimeout)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	var em *errorMsg
+
+	for {
+		item, err := next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var ok bool
+
+			if em, ok = err.(*errorMsg); !ok {
+				em = newErrorMsg(ErrCodeInvalidError, fmt.Sprintf("go-http: stream is interrupted with error: %v", err))
+			}
+
+			break
+		}
+
+		extendWriteDeadline(w, sw.writeTimeout)
+
+		if !sw.ndjson && sw.items > 0 {
+			w.WriteString(",")
+		}
+
+		if err := enc.Encode(item); err != nil {
+			em = newErrorMsg(ErrCodeInvalidError, fmt.Sprintf("go-http: fail to encode stream item with error: %v", err))
+			break
+		}
+
+		sw.items++
+
+		if sw.items%streamFlushItems == 0 {
+			sw.flush()
+		}
+	}
+
+	code := ErrCodeOK
+	msg := ""
+
+	if em != nil {
+		code = em.code
+		msg = em.Error()
+	}
+
+	extendWriteDeadline(w, sw.writeTimeout)
+
+	if sw.ndjson {
+		header.Set(HeaderStreamErr, strconv.Itoa(code))
+		header.Set(HeaderStreamMsg, strings.Join(strings.Fields(msg), " "))
+	} else {
+		// JSON 数组输出到一半时发生错误，数组依然需要完整结束，错误码放在数组之后。
+		fmt.Fprintf(w, `],"err":%v`, code)
+
+		if msg != "" {
+			m, _ := json.Marshal(msg)
+			fmt.Fprintf(w, `,"msg":%s`, m)
+		}
+
+		w.WriteString("}")
+	}
+
+	sw.flush()
+	return em
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testStreamRequest struct {
+	Count int `form:"count"`
+	Fail  int `form:"fail"`
+}
+
+type testStreamItem struct {
+	Seq int `json:"seq"`
+}
+
+func testStreamChan(ctx context.Context, req *testStreamRequest) (<-chan *testStreamItem, error) {
+	ch := make(chan *testStreamItem)
+
+	go func() {
+		defer close(ch)
+
+		for i := 0; i < req.Count; i++ {
+			select {
+			case ch <- &testStreamItem{Seq: i}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func testStreamIterator(ctx context.Context, req *testStreamRequest) (Iterator, error) {
+	if req.Count < 0 {
+		return nil, Error(1000, "invalid count")
+	}
+
+	i := 0
+	return IteratorFunc(func(ctx context.Context) (interface{}, error) {
+		if req.Fail > 0 && i == req.Fail {
+			return nil, Error(1001, "export failed")
+		}
+
+		if i >= req.Count {
+			return nil, io.EOF
+		}
+
+		i++
+		return testStreamItem{Seq: i - 1}, nil
+	}), nil
+}
+
+func TestStreamResponse(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/export": RouteList{
+			R("chan", GET, testStreamChan),
+			R("iterator", GET, testStreamIterator),
+		},
+	}))
+
+	// 流式输出的业务函数没有固定的应答结构。
+	for _, route := range s.Routes() {
+		a.Assert(route.Request != nil)
+		a.Assert(route.Response == nil)
+	}
+
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	type envelope struct {
+		Err  *int             `json:"err"`
+		Msg  string           `json:"msg"`
+		Now  string           `json:"now"`
+		Data []testStreamItem `json:"data"`
+	}
+	get := func(uri string) *envelope {
+		resp, err := http.Get(ts.URL + uri)
+		a.NilError(err)
+		defer resp.Body.Close()
+
+		env := &envelope{}
+		data, err := ioutil.ReadAll(resp.Body)
+		a.NilError(err)
+		a.NilError(json.Unmarshal(data, env))
+		return env
+	}
+
+	env := get("/export/chan?count=200")
+	a.Equal(*env.Err, ErrCodeOK)
+	a.Assert(env.Now != "")
+	a.Equal(len(env.Data), 200)
+	a.Equal(env.Data[199].Seq, 199)
+
+	env = get("/export/iterator?count=0")
+	a.Equal(*env.Err, ErrCodeOK)
+	a.Equal(len(env.Data), 0)
+
+	// 输出到一半时发生错误。
+	env = get("/export/iterator?count=10&fail=3")
+	a.Equal(*env.Err, 1001)
+	a.Equal(len(env.Data), 3)
+
+	// 业务函数直接返回错误时使用普通应答。
+	env = get("/export/iterator?count=-1")
+	a.Equal(*env.Err, 1000)
+
+	// NDJSON 格式。
+	r, err := http.NewRequest(http.MethodGet, ts.URL+"/export/iterator?count=3&fail=2", nil)
+	a.NilError(err)
+	r.Header.Set("Accept", MIMENDJSON)
+	resp, err := http.DefaultClient.Do(r)
+	a.NilError(err)
+	defer resp.Body.Close()
+	a.Equal(resp.Header.Get("Content-Type"), MIMENDJSON)
+
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	a.Equal(lines, []string{`{"seq":0}`, `{"seq":1}`})
+	a.Equal(resp.Trailer.Get(HeaderStreamErr), "1001")
+	a.Assert(resp.Trailer.Get(HeaderStreamMsg) != "")
+}
+
+func TestStreamResponseWriteTimeout(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		WriteTimeout: 200 * time.Millisecond,
+	})
+	a.NilError(s.AddRoutes(RouteList{
+		R("/slow", GET, func(ctx context.Context, req *testStreamRequest) (<-chan *testStreamItem, error) {
+			ch := make(chan *testStreamItem)
+
+			go func() {
+				defer close(ch)
+
+				for i := 0; i < req.Count; i++ {
+					time.Sleep(100 * time.Millisecond)
+
+					select {
+					case ch <- &testStreamItem{Seq: i}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+
+			return ch, nil
+		}),
+	}))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NilError(err)
+	go s.ServeListener(l)
+	defer s.Shutdown(context.Background())
+
+	// 整个应答的时间远远超过 WriteTimeout，但每个元素之间的间隔没有超过，应答不会被中断。
+	resp, err := http.Get("http://" + l.Addr().String() + "/slow?count=6")
+	a.NilError(err)
+	defer resp.Body.Close()
+	res := struct {
+		Err  int               `json:"err"`
+		Data []*testStreamItem `json:"data"`
+	}{}
+	a.NilError(json.NewDecoder(resp.Body).Decode(&res))
+	a.Equal(res.Err, ErrCodeOK)
+	a.Equal(len(res.Data), 6)
+}
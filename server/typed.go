@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// BusinessFunc 是类型安全的业务函数，T 和 U 是请求和应答的结构类型。
+//
+// 与直接注册业务函数相比，BusinessFunc 的签名在编译期检查，
+// 并且处理请求时直接调用函数，不需要通过反射。
+type BusinessFunc[T, U any] func(ctx context.Context, req *T) (res *U, err error)
+
+// Handle 生成一条使用类型安全业务函数的路由记录，可以直接放到 RouteList 里面。
+//
+//     var RouteList = server.RouteList{
+//         server.Handle("create", server.POST, Create),
+//     }
+//
+// T 和 U 必须是结构类型，否则在 AddRoutes 时返回错误。
+func Handle[T, U any](uri string, method Method, fn func(ctx context.Context, req *T) (*U, error)) *Route {
+	return R(uri, method, BusinessFunc[T, U](fn))
+}
+
+func (fn BusinessFunc[T, U]) parse() (handlerFunc, error) {
+	if fn == nil {
+		return nil, errors.New("go-http: handler must be valid")
+	}
+
+	plan := newBindingPlan(reflect.TypeOf((*T)(nil)))
+
+	if plan.typ.Kind() != reflect.Struct || reflect.TypeOf((*U)(nil)).Elem().Kind() != reflect.Struct {
+		return nil, errors.New("go-http: type of the handler is not supported")
+	}
+
+	return wrapHandlerFunc(func(c *httpContext) {
+		ctx := c.Request.Context()
+		req := new(T)
+
+		if em := plan.bind(c, req); em != nil {
+			writeEnvelope(ctx, c, bindStatus(em), em, nil)
+			return
+		}
+
+		res, err := fn(ctx, req)
+		var data interface{}
+
+		if res != nil {
+			data = res
+		}
+
+		writeBusinessResponse(ctx, c, data, err)
+	}), nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testTypedRequest struct {
+	UID  int64  `form:"uid"`
+	Name string `json:"name"`
+}
+
+type testTypedResponse struct {
+	UID  int64  `json:"uid"`
+	Name string `json:"name"`
+}
+
+func testTyped(ctx context.Context, req *testTypedRequest) (*testTypedResponse, error) {
+	switch req.Name {
+	case "":
+		return nil, Error(1001, "name is required")
+	case "invalid":
+		return nil, http.ErrBodyNotAllowed
+	}
+
+	return &testTypedResponse{
+		UID:  req.UID,
+		Name: req.Name,
+	}, nil
+}
+
+func TestHandle(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/typed": RouteList{
+			Handle("echo", POST, testTyped),
+		},
+	}))
+
+	call := func(body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := httptest.NewRequest(http.MethodPost, "/typed/echo?uid=12", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		res := map[string]interface{}{}
+		a.NilError(json.Unmarshal(w.Body.Bytes(), &res))
+		return w, res
+	}
+
+	w, res := call(`{"name":"foo"}`)
+	a.Equal(w.Code, http.StatusOK)
+	a.Equal(res["err"], float64(ErrCodeOK))
+	a.Equal(res["data"], map[string]interface{}{"uid": float64(12), "name": "foo"})
+
+	w, res = call(`{}`)
+	a.Equal(w.Code, http.StatusOK)
+	a.Equal(res["err"], float64(1001))
+	a.Equal(res["data"], nil)
+
+	w, res = call(`{"name":"invalid"}`)
+	a.Equal(w.Code, http.StatusInternalServerError)
+	a.Equal(res["err"], float64(ErrCodeInvalidError))
+
+	w, res = call(`{"name"}`)
+	a.Equal(w.Code, http.StatusBadRequest)
+	a.Equal(res["err"], float64(ErrCodeBadRequest))
+
+	// 路由信息与普通业务函数一致。
+	routes := s.Routes()
+	a.Equal(len(routes), 1)
+	a.Equal(routes[0].Request, reflect.TypeOf(testTypedRequest{}))
+	a.Equal(routes[0].Response, reflect.TypeOf(testTypedResponse{}))
+	a.Assert(strings.HasSuffix(routes[0].Handler, ".testTyped"))
+}
+
+func TestHandleInvalid(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+
+	a.NonNilError(s.AddRoutes(RouteList{
+		Handle[testTypedRequest, testTypedResponse]("nil", GET, nil),
+	}))
+	a.NonNilError(s.AddRoutes(RouteList{
+		Handle("string", GET, func(ctx context.Context, req *string) (*testTypedResponse, error) {
+			return nil, nil
+		}),
+	}))
+}
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+const (
+	// DefaultUpgradeTimeout 是热升级时等待新进程就绪的默认超时时间。
+	DefaultUpgradeTimeout = 30 * time.Second
+
+	// envUpgradeReadyFD 是热升级时父进程传给新进程的就绪通知 fd。
+	envUpgradeReadyFD = "GO_HTTP_UPGRADE_READY_FD"
+)
+
+// boundListener 记录一个 listener 以及创建它时使用的地址配置。
+type boundListener struct {
+	addr     string
+	listener net.Listener
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+// listenerName 将地址配置转换成可以放在 LISTEN_FDNAMES 里面的名字。
+// LISTEN_FDNAMES 用“:”分隔，所以需要转义。
+func listenerName(addr string) string {
+	return url.QueryEscape(addr)
+}
+
+// upgradeGroup 记录当前进程里所有正在提供服务的 Server。
+//
+// 热升级是进程级别的操作：一个进程里可能有多个 NamedServer，收到 SIGUSR2 时只能启动一个新进程，
+// 并把所有 Server 的 listener 一起交给它，否则新进程里其他 Server 会因为地址已被占用而无法启动。
+type upgradeGroup struct {
+	mu      sync.Mutex
+	servers map[*Server]chan struct{}
+	signals chan os.Signal
+}
+
+var upgrades = &upgradeGroup{}
+
+// join 将 s 加入热升级的范围，返回的 chan 会在热升级成功之后关闭，s 应该随之开始 graceful shutdown。
+// 只要有一个 Server 设置了 Upgrade，整个进程就会处理 SIGUSR2。
+func (g *upgradeGroup) join(s *Server) <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.servers == nil {
+		g.servers = map[*Server]chan struct{}{}
+	}
+
+	done := make(chan struct{})
+	g.servers[s] = done
+
+	if s.upgrade && g.signals == nil {
+		g.signals = make(chan os.Signal, 1)
+		signal.Notify(g.signals, syscall.SIGUSR2)
+		go g.watch(g.signals)
+	}
+
+	return done
+}
+
+// leave 将 s 移出热升级的范围，如果已经没有 Server 设置了 Upgrade，停止处理 SIGUSR2。
+func (g *upgradeGroup) leave(s *Server) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.servers, s)
+
+	if g.signals == nil {
+		return
+	}
+
+	for s := range g.servers {
+		if s.upgrade {
+			return
+		}
+	}
+
+	signal.Stop(g.signals)
+	close(g.signals)
+	g.signals = nil
+}
+
+func (g *upgradeGroup) watch(signals <-chan os.Signal) {
+	for range signals {
+		if err := g.upgrade(); err != nil {
+			log.Errorf(context.Background(), "err=%v||go-http: fail to upgrade", err)
+			continue
+		}
+
+		log.Tracef(context.Background(), "go-http: new process is ready and current process is exiting")
+	}
+}
+
+// upgrade 启动一个新进程并将所有 Server 的 listener 交给它，成功之后通知所有 Server 退出。
+// 等待新进程的超时时间取所有 Server 中最长的 UpgradeTimeout。
+func (g *upgradeGroup) upgrade() error {
+	var bound []*boundListener
+	var timeout time.Duration
+
+	g.mu.Lock()
+
+	for s := range g.servers {
+		bound = append(bound, s.bound...)
+
+		if s.upgradeTimeout > timeout {
+			timeout = s.upgradeTimeout
+		}
+	}
+
+	g.mu.Unlock()
+
+	if timeout <= 0 {
+		timeout = DefaultUpgradeTimeout
+	}
+
+	if err := handoff(bound, timeout); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for s, done := range g.servers {
+		close(done)
+		delete(g.servers, s)
+	}
+
+	return nil
+}
+
+// notifyReady 在所有继承来的 listener 都有 Server 使用之后通知父进程当前进程已经就绪。
+// 如果只要有一个 Server 启动就通知父进程，父进程退出之后其他 Server 的 listener 会暂时无人处理。
+func (g *upgradeGroup) notifyReady() {
+	inherited, _ := loadInheritedListeners()
+	used := map[net.Listener]bool{}
+
+	g.mu.Lock()
+
+	for s := range g.servers {
+		for _, bl := range s.bound {
+			used[bl.listener] = true
+		}
+	}
+
+	g.mu.Unlock()
+
+	for _, il := range inherited {
+		if !used[il.listener] {
+			return
+		}
+	}
+
+	notifyUpgradeReady()
+}
+
+// handoff 启动一个新的进程，并将 bound 中所有 listener 交给新进程。
+// 新进程就绪之后这个函数返回 nil，调用者应该开始 graceful shutdown。
+func handoff(bound []*boundListener, timeout time.Duration) error {
+	ctx := context.Background()
+	path, err := os.Executable()
+
+	if err != nil {
+		return err
+	}
+
+	var files []*os.File
+	var names []string
+
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, bl := range bound {
+		fl, ok := bl.listener.(filer)
+
+		if !ok {
+			return fmt.Errorf("go-http: listener cannot be passed to new process [addr:%v]", bl.addr)
+		}
+
+		// 新进程会继续使用这个 socket 文件，当前进程关闭 listener 的时候不能删除它。
+		if ul, ok := bl.listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+
+		f, err := fl.File()
+
+		if err != nil {
+			return err
+		}
+
+		files = append(files, f)
+		names = append(names, listenerName(bl.addr))
+	}
+
+	r, w, err := os.Pipe()
+
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	env := make([]string, 0, len(os.Environ())+3)
+
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envListenPID+"=") || strings.HasPrefix(kv, envListenFDs+"=") ||
+			strings.HasPrefix(kv, envListenFDNames+"=") || strings.HasPrefix(kv, envUpgradeReadyFD+"=") {
+			continue
+		}
+
+		env = append(env, kv)
+	}
+
+	env = append(env,
+		envListenFDs+"="+strconv.Itoa(len(files)),
+		envListenFDNames+"="+strings.Join(names, ":"),
+		envUpgradeReadyFD+"="+strconv.Itoa(listenFDsStart+len(files)),
+	)
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.ExtraFiles = append(files, w)
+
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return err
+	}
+
+	w.Close()
+	log.Tracef(ctx, "pid=%v||go-http: new process is started for upgrade", cmd.Process.Pid)
+
+	// 等待新进程就绪，新进程就绪时会写入一个字节，如果新进程退出则会读到 EOF。
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+
+		if _, err := r.Read(buf); err != nil {
+			ready <- fmt.Errorf("go-http: new process exits before it's ready [err:%v]", err)
+			return
+		}
+
+		ready <- nil
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-ready:
+	case <-timer.C:
+		err = errors.New("go-http: timeout when waiting for new process")
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		go cmd.Wait()
+		return err
+	}
+
+	// 不需要等待新进程退出，释放相关资源即可。
+	cmd.Process.Release()
+	return nil
+}
+
+// notifyUpgradeReady 通知父进程当前进程已经就绪。
+// 如果当前进程不是通过热升级启动的，什么都不做。
+func notifyUpgradeReady() {
+	v := os.Getenv(envUpgradeReadyFD)
+
+	if v == "" {
+		return
+	}
+
+	os.Unsetenv(envUpgradeReadyFD)
+	fd, err := strconv.Atoi(v)
+
+	if err != nil {
+		return
+	}
+
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	defer f.Close()
+
+	if _, err := f.Write([]byte{1}); err != nil {
+		log.Warnf(context.Background(), "err=%v||go-http: fail to notify parent process", err)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+const envTestUpgradeAddr = "GO_HTTP_TEST_UPGRADE_ADDR"
+
+func TestServerUpgrade(t *testing.T) {
+	a := assert.New(t)
+	const addr = "127.0.0.1:0"
+	s := New(&Config{
+		Addr: addr,
+	})
+	ls, err := s.listen(s.addrs)
+	a.NilError(err)
+	a.Equal(len(ls), 1)
+	url := "http://" + ls[0].Addr().String()
+
+	os.Setenv(envTestUpgradeAddr, addr)
+	defer os.Unsetenv(envTestUpgradeAddr)
+	a.NilError(handoff(s.bound, 10*time.Second))
+	ls[0].Close()
+
+	// 新进程已经在同一个 listener 上提供服务了。
+	resp, err := http.Get(url + "/pid")
+	a.NilError(err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	a.NotEqual(string(body), strconv.Itoa(os.Getpid()))
+
+	resp, err = http.Get(url + "/quit")
+	a.NilError(err)
+	resp.Body.Close()
+}
+
+func TestServerUpgradeNamedServers(t *testing.T) {
+	a := assert.New(t)
+	addrs := []string{"127.0.0.1:0", "tcp://127.0.0.1:0"}
+	var urls []string
+
+	for _, addr := range addrs {
+		s := New(&Config{
+			Addr: addr,
+		})
+		ls, err := s.listen(s.addrs)
+		a.NilError(err)
+		a.Equal(len(ls), 1)
+		urls = append(urls, "http://"+ls[0].Addr().String())
+
+		upgraded := upgrades.join(s)
+		defer upgrades.leave(s)
+		defer ls[0].Close()
+		defer func() {
+			select {
+			case <-upgraded:
+			default:
+				t.Errorf("server is not notified to shutdown [addr:%v]", addr)
+			}
+		}()
+	}
+
+	os.Setenv(envTestUpgradeAddr, strings.Join(addrs, ","))
+	defer os.Unsetenv(envTestUpgradeAddr)
+
+	// 子进程会延迟启动第二个 Server，只有所有 Server 都启动之后才能通知父进程。
+	start := time.Now()
+	a.NilError(upgrades.upgrade())
+	a.Assert(time.Since(start) >= testUpgradeServeDelay)
+
+	// 所有 listener 都交给了同一个新进程。
+	var pids []string
+
+	for _, url := range urls {
+		resp, err := http.Get(url + "/pid")
+		a.NilError(err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		pids = append(pids, string(body))
+	}
+
+	a.NotEqual(pids[0], strconv.Itoa(os.Getpid()))
+	a.Equal(pids[0], pids[1])
+
+	for _, url := range urls {
+		resp, err := http.Get(url + "/quit")
+		a.NilError(err)
+		resp.Body.Close()
+	}
+}
+
+// testUpgradeServeDelay 是子进程里启动每个 Server 之间的间隔。
+const testUpgradeServeDelay = 300 * time.Millisecond
+
+// runUpgradedTestServer 在热升级启动的子进程里面为 addrs 中的每个地址启动一个 Server，直到都收到 /quit 请求。
+// addrs 用“,”分隔。
+func runUpgradedTestServer(addrs string) {
+	var wg sync.WaitGroup
+
+	for i, addr := range strings.Split(addrs, ",") {
+		if i > 0 {
+			time.Sleep(testUpgradeServeDelay)
+		}
+
+		s := New(&Config{
+			Addr: addr,
+		})
+		s.engine.Handle(http.MethodGet, "/pid", func(c *httpContext) {
+			c.Writer.WriteString(strconv.Itoa(os.Getpid()))
+		})
+		s.engine.Handle(http.MethodGet, "/quit", func(c *httpContext) {
+			go s.Shutdown(context.Background())
+		})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Serve()
+		}()
+	}
+
+	wg.Wait()
+}
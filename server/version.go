@@ -0,0 +1,370 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderAcceptVersion 是客户端用来指定 API 版本的 HTTP header。
+	HeaderAcceptVersion = "Accept-Version"
+
+	// HeaderDeprecation 是标记接口已经废弃的 HTTP header。
+	HeaderDeprecation = "Deprecation"
+
+	// HeaderSunset 是接口计划下线时间的 HTTP header，格式详见 RFC 8594。
+	HeaderSunset = "Sunset"
+)
+
+// Deprecate 将路由标记为废弃，sunset 是计划下线的时间，零值表示还没有确定下线时间。
+//
+// 调用废弃的路由时，框架会在应答中设置 Deprecation 和 Sunset header，并记录 api_deprecated 监控。
+func (r *Route) Deprecate(sunset time.Time) *Route {
+	handlers := make([]Handler, 0, len(r.Handlers)+1)
+	handlers = append(handlers, &deprecation{sunset: sunset})
+	r.Handlers = append(handlers, r.Handlers...)
+	return r
+}
+
+// deprecation 是标记路由废弃的中间件。
+type deprecation struct {
+	sunset time.Time
+}
+
+func (d *deprecation) parse() (handlerFunc, error) {
+	var sunset string
+
+	if !d.sunset.IsZero() {
+		sunset = d.sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(c *httpContext) {
+		header := c.Writer.Header()
+		header.Set(HeaderDeprecation, "true")
+
+		if sunset != "" {
+			header.Set(HeaderSunset, sunset)
+		}
+
+		httpMetrics.Deprecated.AddForTag(c.FullPath(), 1)
+	}, nil
+}
+
+// Version 是一个版本的路由配置。
+type Version struct {
+	Name       string    // Name 是版本名，例如 "v1"，这个版本的路由会挂载在 /v1 下面。
+	Routes     Routes    // Routes 是这个版本的路由。
+	Overrides  Routes    // Overrides 会替换 Routes 中方法和路径都相同的路由，也可以增加 Routes 里没有的路由。
+	Deprecated bool      // Deprecated 表示这个版本的所有路由都已经废弃。
+	Sunset     time.Time // Sunset 是废弃版本计划下线的时间。
+}
+
+// VersionedRoutes 是按照版本组织的路由配置表。
+//
+// 每个版本的路由都会挂载在版本名对应的路径下，例如 /v1/user/login 和 /v2/user/login。
+// 如果设置了 Default，所有路由还会以不带版本的路径注册，例如 /user/login，
+// 框架按照下面的顺序决定使用哪个版本：
+//     - Accept-Version header，例如 "Accept-Version: v2"；
+//     - Accept header 中 media type 的 version 参数，例如 "Accept: application/json; version=v2"；
+//     - Accept header 中 media type 包含的版本名，例如 "Accept: application/vnd.project.v2+json"；
+//     - 都没有指定时使用 Default。
+type VersionedRoutes struct {
+	Default  string
+	Versions []*Version
+}
+
+// Register 将所有版本的路由注册到 router 里面去。
+func (vr *VersionedRoutes) Register(router Router) error {
+	names := make([]string, 0, len(vr.Versions))
+	dispatchers := map[string]*versionDispatcher{}
+	var dispatched []*recordedRoute
+
+	for _, v := range vr.Versions {
+		if v.Name == "" || strings.Contains(v.Name, "/") {
+			return fmt.Errorf("go-http: invalid version name [version:%v]", v.Name)
+		}
+
+		names = append(names, v.Name)
+		routes, err := v.routes()
+
+		if err != nil {
+			return err
+		}
+
+		for _, r := range routes {
+			if err := handleRoute(router, r.method, JoinPaths("/"+v.Name, r.path), r.handlers); err != nil {
+				return err
+			}
+
+			// 不带版本的路由直接调用对应版本的处理函数，不需要再经过路由引擎和中间件。
+			hfs, err := parseHandlers(r.handlers)
+
+			if err != nil {
+				return fmt.Errorf("go-http: invalid route [method:%v] [path:%v] [err:%v]", r.method, r.path, err)
+			}
+
+			key := r.key()
+			vd, ok := dispatchers[key]
+
+			if !ok {
+				vd = &versionDispatcher{
+					path:     r.path,
+					handlers: map[string][]handlerFunc{},
+				}
+				dispatchers[key] = vd
+				dispatched = append(dispatched, r)
+			}
+
+			vd.handlers[v.Name] = hfs
+		}
+	}
+
+	if vr.Default == "" {
+		return nil
+	}
+
+	if !containsString(names, vr.Default) {
+		return fmt.Errorf("go-http: default version is not defined [version:%v]", vr.Default)
+	}
+
+	for _, r := range dispatched {
+		vd := dispatchers[r.key()]
+		vd.names = names
+		vd.def = vr.Default
+
+		if err := handleRoute(router, r.method, r.path, []Handler{vd}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// routes 返回这个版本的所有路由，Overrides 中的路由会替换 Routes 中方法和路径相同的路由。
+func (v *Version) routes() ([]*recordedRoute, error) {
+	var routes, overrides []*recordedRoute
+
+	if v.Routes != nil {
+		if err := v.Routes.Register(newRouteRecorder(&routes)); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.Overrides != nil {
+		if err := v.Overrides.Register(newRouteRecorder(&overrides)); err != nil {
+			return nil, err
+		}
+	}
+
+	replaced := make(map[string]*recordedRoute, len(overrides))
+
+	for _, r := range overrides {
+		replaced[r.key()] = r
+	}
+
+	merged := make([]*recordedRoute, 0, len(routes)+len(overrides))
+
+	for _, r := range routes {
+		if o, ok := replaced[r.key()]; ok {
+			r = o
+			delete(replaced, r.key())
+		}
+
+		merged = append(merged, r)
+	}
+
+	for _, r := range overrides {
+		if _, ok := replaced[r.key()]; ok {
+			merged = append(merged, r)
+		}
+	}
+
+	if v.Deprecated {
+		for i, r := range merged {
+			handlers := make([]Handler, 0, len(r.handlers)+1)
+			handlers = append(handlers, &deprecation{sunset: v.Sunset})
+			handlers = append(handlers, r.handlers...)
+			merged[i] = &recordedRoute{
+				method:   r.method,
+				path:     r.path,
+				handlers: handlers,
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+func handleRoute(router Router, method Method, uri string, handlers []Handler) error {
+	if method == ANY {
+		return router.HandleAny(uri, handlers...)
+	}
+
+	return router.Handle(method, uri, handlers...)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordedRoute 是 routeRecorder 记录的一条路由，handlers 包含了 SubRouter 的中间件。
+type recordedRoute struct {
+	method   Method
+	path     string
+	handlers []Handler
+}
+
+func (r *recordedRoute) key() string {
+	return r.method.String() + " " + r.path
+}
+
+// routeRecorder 只记录路由，不检查处理函数，处理函数会在真正注册时检查。
+type routeRecorder struct {
+	prefix   string
+	handlers []Handler
+	routes   *[]*recordedRoute
+}
+
+func newRouteRecorder(routes *[]*recordedRoute) *routeRecorder {
+	return &routeRecorder{
+		prefix: "/",
+		routes: routes,
+	}
+}
+
+func (rr *routeRecorder) SubRouter(uri string, handlers ...Handler) (Router, error) {
+	sub := &routeRecorder{
+		prefix: JoinPaths(rr.prefix, uri),
+		routes: rr.routes,
+	}
+	sub.handlers = append(sub.handlers, rr.handlers...)
+	sub.handlers = append(sub.handlers, handlers...)
+	return sub, nil
+}
+
+func (rr *routeRecorder) Handle(method Method, uri string, handlers ...Handler) error {
+	all := make([]Handler, 0, len(rr.handlers)+len(handlers))
+	all = append(all, rr.handlers...)
+	all = append(all, handlers...)
+	*rr.routes = append(*rr.routes, &recordedRoute{
+		method:   method,
+		path:     JoinPaths(rr.prefix, uri),
+		handlers: all,
+	})
+	return nil
+}
+
+func (rr *routeRecorder) HandleAny(uri string, handlers ...Handler) error {
+	return rr.Handle(ANY, uri, handlers...)
+}
+
+// versionDispatcher 根据请求中指定的版本，将不带版本的请求交给对应版本的处理函数。
+type versionDispatcher struct {
+	path     string                   // path 是路由相对于 VersionedRoutes 挂载点的路径。
+	handlers map[string][]handlerFunc // handlers 是每个版本的处理函数，包含版本内 SubRouter 的中间件。
+	names    []string
+	def      string
+}
+
+func (vd *versionDispatcher) parse() (handlerFunc, error) {
+	rejectVersion := wrapHandlerFunc(func(c *httpContext) {
+		version := requestedVersion(c.Request, vd.names)
+		writeEnvelope(c.Request.Context(), c, http.StatusNotAcceptable, newErrorMsg(ErrCodeBadRequest, fmt.Sprintf("go-http: version is not supported [version:%v]", version)), nil)
+	})
+	rejectPath := wrapHandlerFunc(func(c *httpContext) {
+		writeEnvelope(c.Request.Context(), c, http.StatusNotFound, newErrorMsg(ErrCodeNotFound, fmt.Sprintf("go-http: no route matches the path [path:%v]", c.Request.URL.Path)), nil)
+	})
+
+	return func(c *httpContext) {
+		c.Writer.Header().Add("Vary", HeaderAcceptVersion+", Accept")
+
+		version := requestedVersion(c.Request, vd.names)
+
+		if version == "" {
+			version = vd.def
+		} else if !containsString(vd.names, version) {
+			rejectVersion(c)
+			return
+		}
+
+		// 根据路由的完整路径计算出 VersionedRoutes 的挂载点，在挂载点后面插入版本名。
+		mount := strings.TrimSuffix(strings.TrimSuffix(c.FullPath(), vd.path), "/")
+		uri := c.Request.URL.Path
+
+		if !strings.HasPrefix(uri, mount) {
+			rejectPath(c)
+			return
+		}
+
+		r := c.Request.Clone(c.Request.Context())
+		r.URL.Path = mount + "/" + version + uri[len(mount):]
+		r.URL.RawPath = ""
+		c.Request = r
+		handlers, ok := vd.handlers[version]
+
+		// 这个版本没有定义这条路由，按照带版本的路径处理。
+		if !ok {
+			writeNoRoute(c)
+			return
+		}
+
+		c.route = mount + "/" + version + vd.path
+
+		// 用这个版本的处理函数替换剩下的处理函数，Next 会继续执行它们。
+		next := c.index + 1
+		c.handlers = append(c.handlers[:next:next], handlers...)
+	}, nil
+}
+
+// requestedVersion 返回请求中指定的版本，如果没有指定则返回空字符串。
+func requestedVersion(r *http.Request, names []string) string {
+	if v := strings.TrimSpace(r.Header.Get(HeaderAcceptVersion)); v != "" {
+		return normalizeVersion(v, names)
+	}
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+
+			if err != nil {
+				continue
+			}
+
+			if v := params["version"]; v != "" {
+				return normalizeVersion(v, names)
+			}
+
+			idx := strings.IndexByte(mediaType, '/')
+
+			if idx < 0 {
+				continue
+			}
+
+			for _, token := range strings.FieldsFunc(mediaType[idx+1:], func(r rune) bool { return r == '.' || r == '+' }) {
+				if containsString(names, token) {
+					return token
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// normalizeVersion 允许客户端省略版本名前面的 v，例如 "2" 等价于 "v2"。
+func normalizeVersion(v string, names []string) string {
+	if !containsString(names, v) && containsString(names, "v"+v) {
+		return "v" + v
+	}
+
+	return v
+}
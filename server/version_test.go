@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testVersionRequest struct {
+	ID string `form:"id"`
+}
+
+type testVersionResponse struct {
+	Version string `json:"version"`
+	ID      string `json:"id"`
+}
+
+func testVersionHandler(version string) func(ctx context.Context, req *testVersionRequest) (*testVersionResponse, error) {
+	return func(ctx context.Context, req *testVersionRequest) (*testVersionResponse, error) {
+		return &testVersionResponse{
+			Version: version,
+			ID:      Param(ctx, "id"),
+		}, nil
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	a := assert.New(t)
+	sunset := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	users := RouteList{
+		R("/user/:id", GET, testVersionHandler("v1")),
+		R("/user/:id", DELETE, testVersionHandler("v1")),
+	}
+
+	for _, engine := range testEngines {
+		s := New(&Config{
+			Engine: engine,
+		})
+		a.NilError(s.AddRoutes(RouteMap{
+			"/api": &VersionedRoutes{
+				Default: "v1",
+				Versions: []*Version{
+					{
+						Name:       "v1",
+						Routes:     users,
+						Deprecated: true,
+						Sunset:     sunset,
+					},
+					{
+						Name:   "v2",
+						Routes: users,
+						Overrides: RouteList{
+							R("/user/:id", GET, testVersionHandler("v2")),
+							R("/user/:id/profile", GET, testVersionHandler("v2")).Deprecate(time.Time{}),
+						},
+					},
+				},
+			},
+		}))
+
+		call := func(method, uri string, header http.Header) (*httptest.ResponseRecorder, *testVersionResponse) {
+			r := httptest.NewRequest(method, uri, nil)
+
+			for k, v := range header {
+				r.Header[k] = v
+			}
+
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+			res := &struct {
+				Data *testVersionResponse `json:"data"`
+			}{}
+			a.NilError(json.Unmarshal(w.Body.Bytes(), res))
+			return w, res.Data
+		}
+
+		// 直接访问带版本的路径。
+		w, res := call(http.MethodGet, "/api/v2/user/12", nil)
+		a.Equal(w.Code, http.StatusOK)
+		a.Equal(res, &testVersionResponse{Version: "v2", ID: "12"})
+		a.Equal(w.Header().Get(HeaderDeprecation), "")
+
+		w, res = call(http.MethodDelete, "/api/v2/user/12", nil)
+		a.Equal(res.Version, "v1")
+
+		w, res = call(http.MethodGet, "/api/v1/user/12", nil)
+		a.Equal(res.Version, "v1")
+		a.Equal(w.Header().Get(HeaderDeprecation), "true")
+		a.Equal(w.Header().Get(HeaderSunset), "Wed, 02 Jan 2030 03:04:05 GMT")
+
+		// 不带版本的路径根据 header 选择版本。
+		w, res = call(http.MethodGet, "/api/user/34", nil)
+		a.Equal(res, &testVersionResponse{Version: "v1", ID: "34"})
+		a.Equal(w.Header().Get(HeaderDeprecation), "true")
+		a.Assert(strings.Contains(w.Header().Get("Vary"), HeaderAcceptVersion))
+
+		w, res = call(http.MethodGet, "/api/user/34", http.Header{HeaderAcceptVersion: {"2"}})
+		a.Equal(res, &testVersionResponse{Version: "v2", ID: "34"})
+		a.Equal(w.Header().Get(HeaderDeprecation), "")
+
+		_, res = call(http.MethodGet, "/api/user/34", http.Header{"Accept": {"application/json; version=v2"}})
+		a.Equal(res.Version, "v2")
+
+		_, res = call(http.MethodGet, "/api/user/34", http.Header{"Accept": {"text/html, application/vnd.project.v2+json"}})
+		a.Equal(res.Version, "v2")
+
+		// 只在 v2 中存在的路由。
+		w, res = call(http.MethodGet, "/api/v2/user/56/profile", nil)
+		a.Equal(res, &testVersionResponse{Version: "v2", ID: "56"})
+		a.Equal(w.Header().Get(HeaderDeprecation), "true")
+		a.Equal(w.Header().Get(HeaderSunset), "")
+
+		// 路由信息中包含废弃标记。
+		deprecated := map[string]bool{}
+
+		for _, info := range s.Routes() {
+			deprecated[info.Method.String()+" "+info.Path] = info.Deprecated
+			a.Equal(len(info.Middlewares), 0)
+		}
+
+		a.Equal(deprecated, map[string]bool{
+			"GET /api/v1/user/:id":         true,
+			"DELETE /api/v1/user/:id":      true,
+			"GET /api/v2/user/:id":         false,
+			"DELETE /api/v2/user/:id":      false,
+			"GET /api/v2/user/:id/profile": true,
+			"GET /api/user/:id":            false,
+			"DELETE /api/user/:id":         false,
+			"GET /api/user/:id/profile":    false,
+		})
+	}
+}
+
+func TestVersionedRoutesInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := ListRoutes(&VersionedRoutes{
+		Versions: []*Version{{Name: "v1/beta"}},
+	})
+	a.NonNilError(err)
+
+	_, err = ListRoutes(&VersionedRoutes{
+		Default: "v3",
+		Versions: []*Version{
+			{Name: "v1", Routes: RouteList{R("/ping", GET, testVersionHandler("v1"))}},
+		},
+	})
+	a.NonNilError(err)
+}
+
+func TestRequestedVersion(t *testing.T) {
+	a := assert.New(t)
+	names := []string{"v1", "v2", "2021-01"}
+	cases := []struct {
+		header  http.Header
+		version string
+	}{
+		{nil, ""},
+		{http.Header{HeaderAcceptVersion: {"v2"}}, "v2"},
+		{http.Header{HeaderAcceptVersion: {"1"}}, "v1"},
+		{http.Header{HeaderAcceptVersion: {"2021-01"}}, "2021-01"},
+		{http.Header{HeaderAcceptVersion: {"v2"}, "Accept": {"application/json; version=v1"}}, "v2"},
+		{http.Header{"Accept": {"application/json; version=2"}}, "v2"},
+		{http.Header{"Accept": {"application/vnd.project.v1+json"}}, "v1"},
+		{http.Header{"Accept": {"application/vnd.project.v3+json"}}, ""},
+		{http.Header{"Accept": {"*/*"}}, ""},
+	}
+
+	for i, c := range cases {
+		a.Use(i, c)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header = c.header
+
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+
+		a.Equal(requestedVersion(r, names), c.version)
+	}
+}
+
+func TestVersionedRoutesDispatch(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-http-test")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	calls := 0
+	counter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	file := filepath.Join(dir, "capture.jsonl")
+	s := New(&Config{
+		CaptureFile: file,
+	})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/api": &VersionedRoutes{
+			Default: "v1",
+			Versions: []*Version{
+				{
+					Name: "v1",
+					Routes: RouteList{
+						R("/user/:id", GET, counter, testVersionHandler("v1")),
+					},
+				},
+				{
+					Name: "v2",
+					Routes: RouteList{
+						R("/user/:id", GET, counter, testVersionHandler("v2")),
+						R("/user/:id/profile", GET, testVersionHandler("v2")),
+					},
+				},
+			},
+		},
+	}))
+
+	call := func(uri string, header http.Header) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := httptest.NewRequest(http.MethodGet, uri, nil)
+
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		var body map[string]interface{}
+		a.NilError(json.Unmarshal(w.Body.Bytes(), &body))
+		return w, body
+	}
+
+	// 中间件只执行一次。
+	w, body := call("/api/user/34", http.Header{HeaderAcceptVersion: {"v2"}})
+	a.Equal(w.Code, http.StatusOK)
+	a.Equal(body["data"], map[string]interface{}{"version": "v2", "id": "34"})
+	a.Equal(calls, 1)
+
+	// 不支持的版本。
+	w, body = call("/api/user/34", http.Header{HeaderAcceptVersion: {"v9"}})
+	a.Equal(w.Code, http.StatusNotAcceptable)
+	a.Equal(body["err"], float64(ErrCodeBadRequest))
+
+	w, _ = call("/api/user/34", http.Header{"Accept": {"application/json; version=v9"}})
+	a.Equal(w.Code, http.StatusNotAcceptable)
+	a.Equal(calls, 1)
+
+	// 默认版本没有定义的路由。
+	w, body = call("/api/user/56/profile", nil)
+	a.Equal(w.Code, http.StatusNotFound)
+	a.Equal(body["err"], float64(ErrCodeNotFound))
+
+	// 每个请求只录制一次。
+	a.NilError(s.Shutdown(context.Background()))
+	data, err := ioutil.ReadFile(file)
+	a.NilError(err)
+	a.Equal(strings.Count(string(data), "\n"), 4)
+}
@@ -0,0 +1,451 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/altstory/go-log"
+)
+
+const (
+	// DefaultWSPingInterval 是 WebSocket 默认的 ping 间隔。
+	DefaultWSPingInterval = 30 * time.Second
+
+	// wsWriteTimeout 是 WebSocket 写消息的超时时间。
+	wsWriteTimeout = 10 * time.Second
+)
+
+var typeOfWSConn = reflect.TypeOf((*WSConn)(nil))
+
+// WSCodec 用来编解码 WebSocket 消息。
+type WSCodec interface {
+	MessageType() int                           // MessageType 返回发送消息时使用的类型，即 websocket.TextMessage 或 websocket.BinaryMessage。
+	Marshal(v interface{}) ([]byte, error)      // Marshal 将 v 编码成消息。
+	Unmarshal(data []byte, v interface{}) error // Unmarshal 将消息解码到 v 里。
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MessageType() int                           { return websocket.TextMessage }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// JSONCodec 是默认的 WebSocket 消息编解码器，所有消息都编码成 JSON 文本消息。
+var JSONCodec WSCodec = jsonCodec{}
+
+// WSOption 是 WebSocket 路由的选项。
+type WSOption func(h *wsHandler)
+
+// WithWSCodec 设置 WebSocket 消息的编解码器，默认是 JSONCodec。
+func WithWSCodec(codec WSCodec) WSOption {
+	return func(h *wsHandler) {
+		if codec != nil {
+			h.codec = codec
+		}
+	}
+}
+
+// WithWSCheckOrigin 设置检查 Origin header 的函数，默认只允许同源请求。
+func WithWSCheckOrigin(checkOrigin func(r *http.Request) bool) WSOption {
+	return func(h *wsHandler) {
+		h.upgrader.CheckOrigin = checkOrigin
+	}
+}
+
+// WithWSReadLimit 设置单个消息的最大字节数，超过的消息会导致连接关闭，
+// 默认与 Config 中的 MaxBodyBytes 相同，limit 小于 0 表示不限制。
+func WithWSReadLimit(limit int64) WSOption {
+	return func(h *wsHandler) {
+		h.readLimit = limit
+	}
+}
+
+// WS 生成一条 WebSocket 路由记录，使用 GET 方法接受连接。
+//
+// onConnect 在连接建立之后调用，可以为 nil，函数签名是：
+//     - func(ctx context.Context, req *T, conn *WSConn) error：req 从连接请求的 query 里解析，规则与业务函数一致，
+//                                                               返回错误会关闭连接。
+//
+// onMessage 在每次收到消息时调用，函数签名是：
+//     - func(ctx context.Context, conn *WSConn, msg *M) error：msg 由编解码器从消息中解码，
+//                                                               返回通过 Error 构造的业务错误时，错误会以 `{"err":1,"msg":""}` 的格式发给客户端，
+//                                                               返回其他错误会关闭连接。
+//
+// 所有回调使用的 ctx 都来自同一个连接级别的 ctx，包含与普通请求一样的 traceid 等日志信息，连接关闭后 ctx 会被取消。
+func WS(uri string, onConnect, onMessage Handler, opts ...WSOption) *Route {
+	h := &wsHandler{
+		onConnect: onConnect,
+		onMessage: onMessage,
+		codec:     JSONCodec,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return R(uri, GET, h)
+}
+
+// wsHandler 是 WS 生成的处理函数。
+type wsHandler struct {
+	onConnect Handler
+	onMessage Handler
+	codec     WSCodec
+	upgrader  websocket.Upgrader
+	readLimit int64
+}
+
+func (h *wsHandler) parse() (handlerFunc, error) {
+	var connectPlan *bindingPlan
+	vConnect := reflect.ValueOf(h.onConnect)
+
+	if h.onConnect != nil {
+		t := vConnect.Type()
+
+		if t.Kind() != reflect.Func || t.NumIn() != 3 || t.NumOut() != 1 ||
+			!isContextType(t.In(0)) || t.In(2) != typeOfWSConn || t.Out(0) != typeOfError {
+			return nil, errors.New("go-http: type of the websocket connect handler is not supported")
+		}
+
+		connectPlan = newBindingPlan(t.In(1))
+
+		if connectPlan.typ.Kind() != reflect.Struct {
+			return nil, errors.New("go-http: type of the websocket connect handler is not supported")
+		}
+	}
+
+	vMessage := reflect.ValueOf(h.onMessage)
+
+	if !vMessage.IsValid() {
+		return nil, errors.New("go-http: websocket message handler must be valid")
+	}
+
+	t := vMessage.Type()
+
+	if t.Kind() != reflect.Func || t.NumIn() != 3 || t.NumOut() != 1 ||
+		!isContextType(t.In(0)) || t.In(1) != typeOfWSConn || t.Out(0) != typeOfError {
+		return nil, errors.New("go-http: type of the websocket message handler is not supported")
+	}
+
+	msgType := t.In(2)
+	msgIndirect := false
+
+	if msgType.Kind() == reflect.Ptr {
+		msgType = msgType.Elem()
+		msgIndirect = true
+	}
+
+	return wrapHandlerFunc(func(c *httpContext) {
+		ctx := c.Request.Context()
+		var vIn reflect.Value
+
+		if connectPlan != nil {
+			var em *errorMsg
+			vIn, em = bindRequest(c, connectPlan)
+
+			if em != nil {
+				writeResponse(ctx, c, bindStatus(em), em.ToH(nil))
+				return
+			}
+		}
+
+		s := serverFrom(c)
+
+		// Shutdown 开始后不再接受新的连接，http.Server 不会等待已经升级的连接。
+		if s != nil && s.wsConns.isClosing() {
+			writeEnvelope(ctx, c, http.StatusServiceUnavailable, newErrorMsg(ErrCodeBadRequest, "go-http: server is shutting down"), nil)
+			return
+		}
+
+		ws, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+
+		if err != nil {
+			// Upgrade 失败时已经写了应答。
+			log.Warnf(ctx, "err=%v||url=%v||go-http: fail to upgrade to websocket", err, c.Request.URL.Path)
+			reportResponse(ctx, c, ErrCodeBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		uri := c.Request.URL.Path
+		conn := &WSConn{
+			ctx:    ctx,
+			cancel: cancel,
+			conn:   ws,
+			codec:  h.codec,
+		}
+
+		if s != nil {
+			// 升级期间 Shutdown 已经关闭了所有连接，这个连接不会再被关闭，需要直接关闭。
+			if !s.wsConns.add(conn) {
+				conn.closeWithCode(websocket.CloseGoingAway, "server is shutting down")
+				reportResponse(ctx, c, ErrCodeOK)
+				return
+			}
+
+			defer s.wsConns.remove(conn)
+		}
+
+		n := atomic.AddInt64(&wsConnections, 1)
+		wsMetrics.Connect.AddForTag(uri, 1)
+		wsMetrics.Connections.Add(n)
+		defer func() {
+			wsMetrics.Connections.Add(atomic.AddInt64(&wsConnections, -1))
+		}()
+
+		pingInterval := DefaultWSPingInterval
+
+		if s != nil && s.config.WSPingInterval > 0 {
+			pingInterval = s.config.WSPingInterval
+		}
+
+		readLimit := h.readLimit
+
+		if readLimit == 0 && s != nil && s.config.MaxBodyBytes > 0 {
+			readLimit = s.config.MaxBodyBytes
+		}
+
+		code := h.serve(ctx, conn, uri, pingInterval, readLimit, vConnect, vIn, vMessage, msgType, msgIndirect)
+		conn.Close()
+		reportResponse(ctx, c, code)
+	}), nil
+}
+
+// serve 处理一个 WebSocket 连接，直到连接关闭，返回值是连接结束时的业务错误码。
+func (h *wsHandler) serve(ctx context.Context, conn *WSConn, uri string, pingInterval time.Duration, readLimit int64,
+	vConnect, vIn, vMessage reflect.Value, msgType reflect.Type, msgIndirect bool) int {
+	ws := conn.conn
+
+	if readLimit > 0 {
+		ws.SetReadLimit(readLimit)
+	}
+
+	// 客户端需要在两个 ping 间隔内回复 pong，否则认为连接已经断开。
+	ws.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.ping(); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	if vConnect.IsValid() && h.onConnect != nil {
+		returns := vConnect.Call([]reflect.Value{reflect.ValueOf(ctx), vIn, reflect.ValueOf(conn)})
+
+		if err, _ := returns[0].Interface().(error); err != nil {
+			log.Tracef(ctx, "err=%v||url=%v||go-http: websocket connection is rejected", err, uri)
+			conn.closeWithCode(websocket.ClosePolicyViolation, ErrorMessage(err))
+			return ErrorCode(err)
+		}
+	}
+
+	for {
+		_, data, err := ws.ReadMessage()
+
+		if err != nil {
+			if ctx.Err() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				log.Tracef(ctx, "err=%v||url=%v||go-http: websocket connection is broken", err, uri)
+			}
+
+			return ErrCodeOK
+		}
+
+		wsMetrics.Message.AddForTag(uri, 1)
+		msg := reflect.New(msgType)
+
+		if err := h.codec.Unmarshal(data, msg.Interface()); err != nil {
+			conn.Send(newErrorMsg(ErrCodeBadRequest, fmt.Sprintf("go-http: fail to decode websocket message with error: %v", err)).ToH(nil))
+			continue
+		}
+
+		if !msgIndirect {
+			msg = msg.Elem()
+		}
+
+		returns := vMessage.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(conn), msg})
+		err, _ = returns[0].Interface().(error)
+
+		if err == nil {
+			continue
+		}
+
+		if em, ok := err.(*errorMsg); ok {
+			conn.Send(em.ToH(nil))
+			continue
+		}
+
+		log.Warnf(ctx, "err=%v||url=%v||go-http: websocket message handler returns an error", err, uri)
+		conn.closeWithCode(websocket.CloseInternalServerErr, "")
+		return ErrCodeInvalidError
+	}
+}
+
+// WSConn 是一个 WebSocket 连接，所有方法都可以并发调用。
+type WSConn struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	conn   *websocket.Conn
+	codec  WSCodec
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Context 返回连接级别的 ctx，连接关闭后 ctx 会被取消。
+func (c *WSConn) Context() context.Context {
+	return c.ctx
+}
+
+// RemoteAddr 返回客户端地址。
+func (c *WSConn) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// Send 使用编解码器编码 v 并发给客户端。
+func (c *WSConn) Send(v interface{}) error {
+	data, err := c.codec.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	return c.write(c.codec.MessageType(), data)
+}
+
+// Close 正常关闭连接。
+func (c *WSConn) Close() error {
+	return c.closeWithCode(websocket.CloseNormalClosure, "")
+}
+
+func (c *WSConn) ping() error {
+	return c.write(websocket.PingMessage, nil)
+}
+
+func (c *WSConn) write(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return websocket.ErrCloseSent
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// closeWithCode 发送关闭消息并关闭连接，重复调用不会有任何效果。
+func (c *WSConn) closeWithCode(code int, text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	c.cancel()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, truncateCloseText(text)), time.Now().Add(wsWriteTimeout))
+	return c.conn.Close()
+}
+
+// wsMaxCloseTextBytes 是关闭消息里 reason 的最大字节数，控制帧 payload 最多 125 字节，其中 2 字节是 code。
+const wsMaxCloseTextBytes = 123
+
+// truncateCloseText 把关闭消息的 reason 截断到 wsMaxCloseTextBytes 以内，不会截断到 UTF-8 字符中间。
+func truncateCloseText(text string) string {
+	if len(text) <= wsMaxCloseTextBytes {
+		return text
+	}
+
+	n := wsMaxCloseTextBytes
+
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+
+	return text[:n]
+}
+
+var wsConnections int64
+
+// wsConnSet 记录一个 Server 所有打开的 WebSocket 连接，用于在 Shutdown 时关闭它们。
+type wsConnSet struct {
+	mu      sync.Mutex
+	conns   map[*WSConn]struct{}
+	closing bool
+}
+
+// add 记录一个新连接，如果已经开始关闭则不记录，返回 false。
+func (set *wsConnSet) add(conn *WSConn) bool {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if set.closing {
+		return false
+	}
+
+	if set.conns == nil {
+		set.conns = map[*WSConn]struct{}{}
+	}
+
+	set.conns[conn] = struct{}{}
+	return true
+}
+
+func (set *wsConnSet) isClosing() bool {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return set.closing
+}
+
+func (set *wsConnSet) remove(conn *WSConn) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	delete(set.conns, conn)
+}
+
+// closeAll 以 CloseGoingAway 关闭所有连接，之后不再接受新的连接。
+func (set *wsConnSet) closeAll() {
+	set.mu.Lock()
+	set.closing = true
+	conns := make([]*WSConn, 0, len(set.conns))
+
+	for conn := range set.conns {
+		conns = append(conns, conn)
+	}
+
+	set.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.closeWithCode(websocket.CloseGoingAway, "server is shutting down")
+	}
+}
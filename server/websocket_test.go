@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/huandu/go-assert"
+)
+
+type testWSConnectRequest struct {
+	Name string `form:"name"`
+}
+
+type testWSMessage struct {
+	Text string `json:"text"`
+}
+
+func TestWebSocket(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		WSPingInterval: 20 * time.Millisecond,
+	})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/ws": RouteList{
+			WS("chat", func(ctx context.Context, req *testWSConnectRequest, conn *WSConn) error {
+				if req.Name == "" {
+					return Error(1001, "name is required")
+				}
+
+				return conn.Send(&testWSMessage{Text: "welcome " + req.Name})
+			}, func(ctx context.Context, conn *WSConn, msg *testWSMessage) error {
+				if msg.Text == "" {
+					return Error(1002, "empty message")
+				}
+
+				return conn.Send(&testWSMessage{Text: "echo " + msg.Text + " " + TraceID(ctx)})
+			}),
+		},
+	}))
+
+	routes := s.Routes()
+	a.Equal(len(routes), 1)
+	a.Assert(strings.HasSuffix(routes[0].Handler, "TestWebSocket.func2"))
+
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/chat"
+
+	// onConnect 返回错误时关闭连接。
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	a.NilError(err)
+	_, _, err = conn.ReadMessage()
+	a.Assert(websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+	conn.Close()
+
+	conn, _, err = websocket.DefaultDialer.Dial(url+"?name=huandu", map[string][]string{
+		HeaderTraceID: {"trace-ws"},
+	})
+	a.NilError(err)
+	defer conn.Close()
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	var msg testWSMessage
+	a.NilError(conn.ReadJSON(&msg))
+	a.Equal(msg.Text, "welcome huandu")
+
+	a.NilError(conn.WriteJSON(&testWSMessage{Text: "hi"}))
+	a.NilError(conn.ReadJSON(&msg))
+	a.Equal(msg.Text, "echo hi trace-ws")
+
+	// 业务错误会发给客户端，连接不会断开。
+	var env map[string]interface{}
+	a.NilError(conn.WriteJSON(&testWSMessage{}))
+	a.NilError(conn.ReadJSON(&env))
+	a.Equal(env["err"], 1002.0)
+
+	a.NilError(conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	a.NilError(conn.ReadJSON(&env))
+	a.Equal(env["err"], float64(ErrCodeBadRequest))
+
+	// Shutdown 会关闭所有连接，在此期间客户端能收到 ping。
+	go func() {
+		<-pinged
+		s.Shutdown(context.Background())
+	}()
+
+	for {
+		_, _, err = conn.ReadMessage()
+
+		if err != nil {
+			break
+		}
+	}
+
+	a.Assert(websocket.IsCloseError(err, websocket.CloseGoingAway))
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/ws": RouteList{
+			// 第一个参数可以是与 context.Context 兼容的 interface 类型。
+			WS("echo", nil, func(ctx testContext, conn *WSConn, msg *testWSMessage) error {
+				return conn.Send(msg)
+			}),
+		},
+	}))
+
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/echo"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	a.NilError(err)
+	defer conn.Close()
+
+	var msg testWSMessage
+	a.NilError(conn.WriteJSON(&testWSMessage{Text: "hi"}))
+	a.NilError(conn.ReadJSON(&msg))
+	a.Equal(msg.Text, "hi")
+
+	// 开始关闭后，已有连接被关闭，新的连接不会被升级。
+	s.wsConns.closeAll()
+	_, _, err = conn.ReadMessage()
+	a.Assert(websocket.IsCloseError(err, websocket.CloseGoingAway))
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	a.NonNilError(err)
+	a.Equal(resp.StatusCode, http.StatusServiceUnavailable)
+
+	// 关闭之后才登记的连接不会被记录。
+	a.Assert(!s.wsConns.add(&WSConn{}))
+	a.Equal(len(s.wsConns.conns), 0)
+}
+
+func TestWebSocketLimits(t *testing.T) {
+	a := assert.New(t)
+	s := New(&Config{
+		MaxBodyBytes: 64,
+	})
+	a.NilError(s.AddRoutes(RouteMap{
+		"/ws": RouteList{
+			WS("long", func(ctx context.Context, req *testWSConnectRequest, conn *WSConn) error {
+				return Error(1001, strings.Repeat("错", 100))
+			}, func(ctx context.Context, conn *WSConn, msg *testWSMessage) error {
+				return nil
+			}),
+			WS("echo", nil, func(ctx context.Context, conn *WSConn, msg *testWSMessage) error {
+				return conn.Send(msg)
+			}),
+			WS("unlimited", nil, func(ctx context.Context, conn *WSConn, msg *testWSMessage) error {
+				return conn.Send(msg)
+			}, WithWSReadLimit(-1)),
+		},
+	}))
+
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/"
+
+	// 过长的关闭原因会被截断，客户端仍然能收到关闭消息。
+	conn, _, err := websocket.DefaultDialer.Dial(url+"long", nil)
+	a.NilError(err)
+	_, _, err = conn.ReadMessage()
+	a.Assert(websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+	text := err.(*websocket.CloseError).Text
+	a.Assert(len(text) <= wsMaxCloseTextBytes)
+	a.Assert(utf8.ValidString(text))
+	a.Assert(strings.HasPrefix(text, "错"))
+	conn.Close()
+
+	// 默认的消息大小限制与 MaxBodyBytes 相同。
+	large := &testWSMessage{Text: strings.Repeat("x", 128)}
+	conn, _, err = websocket.DefaultDialer.Dial(url+"echo", nil)
+	a.NilError(err)
+	a.NilError(conn.WriteJSON(large))
+	_, _, err = conn.ReadMessage()
+	a.Assert(websocket.IsCloseError(err, websocket.CloseMessageTooBig))
+	conn.Close()
+
+	// WithWSReadLimit 可以覆盖默认限制。
+	var msg testWSMessage
+	conn, _, err = websocket.DefaultDialer.Dial(url+"unlimited", nil)
+	a.NilError(err)
+	defer conn.Close()
+	a.NilError(conn.WriteJSON(large))
+	a.NilError(conn.ReadJSON(&msg))
+	a.Equal(msg.Text, large.Text)
+}
+
+func TestTruncateCloseText(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(truncateCloseText("short"), "short")
+	a.Equal(truncateCloseText(strings.Repeat("x", 200)), strings.Repeat("x", wsMaxCloseTextBytes))
+
+	// 每个字符 3 字节，123 字节刚好是 41 个字符；前面加一个字节后只能保留 40 个字符。
+	a.Equal(truncateCloseText(strings.Repeat("错", 50)), strings.Repeat("错", 41))
+	a.Equal(truncateCloseText("x"+strings.Repeat("错", 50)), "x"+strings.Repeat("错", 40))
+}